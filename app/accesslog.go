@@ -0,0 +1,81 @@
+package app
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/THE108/requestcounter/utils/accesslog"
+	"github.com/THE108/requestcounter/utils/rotate"
+	"github.com/THE108/requestcounter/utils/tracedata"
+)
+
+// initAccessLog creates access logger writing to the file or to stderr if the file is not set
+func (this *Application) initAccessLog() error {
+	if !this.config.AccessLog {
+		return nil
+	}
+
+	var writer io.Writer = os.Stderr
+	if this.config.AccessLogFile != "" {
+		fileWriter, err := this.newRotateWriter(&rotate.Config{
+			Filename:   this.config.AccessLogFile,
+			MaxSize:    this.config.AccessLogMaxSize,
+			MaxAge:     this.config.AccessLogMaxAge,
+			MaxBackups: this.config.AccessLogMaxBackups,
+			Compress:   this.config.AccessLogCompress,
+		})
+		if err != nil {
+			return err
+		}
+
+		this.closer.AddCloser(fileWriter)
+		writer = fileWriter
+	}
+
+	var err error
+	this.accessLogger, err = accesslog.New(&accesslog.Config{
+		Format:     this.config.AccessLogFormat,
+		SampleRate: *this.config.AccessLogSampleRate,
+		Writer:     writer,
+	})
+	return err
+}
+
+// TraceData stores trace data of the request to the request context
+// so the access log and the handler share the same trace ids,
+// the trace id is echoed in the response header
+func TraceData(propagator *tracedata.Propagator, responseHeader string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			traceData := propagator.GetTraceDataFromRequest(req)
+			rw.Header().Set(responseHeader, traceData.TraceID)
+			next.ServeHTTP(rw, req.WithContext(tracedata.SetTraceDataToContext(req.Context(), traceData)))
+		})
+	}
+}
+
+// AccessLog writes an entry for every sampled response
+func AccessLog(logger *accesslog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			start := time.Now()
+			rw := newResponseWriter(w)
+
+			next.ServeHTTP(rw, req)
+
+			if !logger.Sampled(rw.Status()) {
+				return
+			}
+
+			entry := accesslog.NewEntry(req, rw.Status(), rw.size, start)
+			traceData := tracedata.GetTraceDataFromContext(req.Context())
+			entry.TraceID = traceData.TraceID
+			entry.SpanID = traceData.SpanID
+
+			// the log could not respond with an error, write failures are only lost
+			logger.Log(entry)
+		})
+	}
+}
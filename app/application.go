@@ -2,26 +2,42 @@ package app
 
 import (
 	"fmt"
+	"io"
 	"net"
 	"net/http"
-	"os"
 	"strconv"
 
 	"github.com/THE108/requestcounter/config"
+	"github.com/THE108/requestcounter/utils/accesslog"
 	"github.com/THE108/requestcounter/utils/closer"
 	"github.com/THE108/requestcounter/utils/log"
+	"github.com/THE108/requestcounter/utils/tracedata"
+	"github.com/THE108/requestcounter/utils/tracing"
 
 	"github.com/gorilla/mux"
 )
 
 // Application
 type Application struct {
-	config   *config.Config
-	closer   *closer.Closer
-	logger   log.ILogger
-	router   *mux.Router
-	handlers map[string]*HandlerInfo
-	models   models
+	config      *config.Config
+	closer      *closer.Closer
+	logCloser   *closer.Closer
+	logger      log.ILogger
+	router      *mux.Router
+	handler     http.Handler
+	middlewares []Middleware
+	handlers    map[string]*HandlerInfo
+	models      models
+
+	logEncoder      log.IEncoder
+	logOutput       io.Writer
+	logAsync        *log.AsyncWriter
+	logRing         *log.Ring
+	logLevels       *log.Levels
+	debugAccess     *debugAccess
+	tracePropagator *tracedata.Propagator
+	tracer          *tracing.Tracer
+	accessLogger    *accesslog.Logger
 }
 
 // NewApplication creates and initializes new instance of Application
@@ -37,15 +53,36 @@ func (this *Application) init() error {
 		return fmt.Errorf("error parse config file: %s", err.Error())
 	}
 
-	this.logger = log.New(os.Stderr, "Application", this.config.LogLevel)
+	this.closer = closer.NewCloser()
+
+	if err = this.initLogging(); err != nil {
+		return err
+	}
+
 	this.logger.Debug("starting application")
 
-	this.closer = closer.NewCloser()
+	this.debugAccess, err = newDebugAccess(this.config.DebugSecret, this.config.DebugTrustedNetworks)
+	if err != nil {
+		return err
+	}
+
+	this.tracePropagator, err = tracedata.NewPropagator(this.config.TracePropagation)
+	if err != nil {
+		return err
+	}
+
+	if err = this.initTracing(); err != nil {
+		return err
+	}
 
 	if err = this.initModels(); err != nil {
 		return err
 	}
 
+	if err = this.initAccessLog(); err != nil {
+		return fmt.Errorf("error init access log: %s", err.Error())
+	}
+
 	this.initRoutes()
 
 	return nil
@@ -53,6 +90,8 @@ func (this *Application) init() error {
 
 // Run starts the application
 func (this *Application) Run() {
+	defer this.closeLogging()
+
 	if err := this.init(); err != nil {
 		this.logger.Error("error init app:", err.Error())
 		return
@@ -80,7 +119,7 @@ func (this *Application) Run() {
 
 func (this *Application) serve(listener net.Listener, done chan<- struct{}) {
 	server := &http.Server{
-		Handler: this.router,
+		Handler: this.handler,
 	}
 
 	this.logger.ErrorIfNotNil("error serve (could be caused by interrapting application with ^C) ",
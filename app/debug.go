@@ -0,0 +1,225 @@
+package app
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/THE108/requestcounter/utils/errors"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	debugSecretHeader = "X-Debug-Secret"
+	debugLogHeader    = "X-Debug-Log"
+
+	// debugInline is a value of debug param returning logs of the request in X-Debug-Log headers
+	debugInline = "inline"
+
+	maxDebugLogLines      = 100
+	maxDebugLogLineLength = 1024
+	// maxDebugLogBytes limits total size of the log lines so headers fit into common proxy limits
+	maxDebugLogBytes = 8 << 10
+)
+
+var errDebugAccessDenied = errors.New(http.StatusForbidden, "debug access is denied").WithReason("debug_access_denied")
+
+// debugAccess allows debug requests with the secret header or from trusted networks
+type debugAccess struct {
+	secret   []byte
+	networks []*net.IPNet
+}
+
+func newDebugAccess(secret string, networks []string) (*debugAccess, error) {
+	access := &debugAccess{secret: []byte(secret)}
+	for _, network := range networks {
+		_, ipNet, err := net.ParseCIDR(network)
+		if err != nil {
+			return nil, fmt.Errorf("error parse debug trusted network: %s", err.Error())
+		}
+		access.networks = append(access.networks, ipNet)
+	}
+	return access, nil
+}
+
+func (access *debugAccess) Allowed(req *http.Request) bool {
+	if len(access.secret) > 0 {
+		if secret := req.Header.Get(debugSecretHeader); secret != "" {
+			return subtle.ConstantTimeCompare([]byte(secret), access.secret) == 1
+		}
+	}
+
+	// forwarded headers are not trusted, only the peer address is checked
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range access.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+type debugKey struct{}
+
+// debugRequest marks request logged with DEBUG level, capture is set if logs are returned inline
+type debugRequest struct {
+	capture *debugCapture
+}
+
+func getDebugRequest(ctx context.Context) (*debugRequest, bool) {
+	debug, ok := ctx.Value(debugKey{}).(*debugRequest)
+	return debug, ok
+}
+
+// debugRequests enables DEBUG level for requests with debug param if debug access allows them,
+// the param is ignored otherwise. Logs of requests with debug=inline are also returned in X-Debug-Log headers,
+// lines logged after the headers are written are returned in trailers.
+func (this *Application) debugRequests() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			values, ok := req.URL.Query()[debugUrlParamName]
+			if !ok || !this.debugAccess.Allowed(req) {
+				next.ServeHTTP(rw, req)
+				return
+			}
+
+			debug := &debugRequest{}
+			if len(values) == 0 || values[0] != debugInline {
+				next.ServeHTTP(rw, req.WithContext(context.WithValue(req.Context(), debugKey{}, debug)))
+				return
+			}
+
+			debug.capture = &debugCapture{}
+			dw := &debugWriter{ResponseWriter: rw, capture: debug.capture}
+			next.ServeHTTP(dw, req.WithContext(context.WithValue(req.Context(), debugKey{}, debug)))
+			dw.finish()
+		})
+	}
+}
+
+// requireDebugAccess responds with 403 to requests which are not allowed by debug access
+func (this *Application) requireDebugAccess() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if !this.debugAccess.Allowed(req) {
+				this.writeFallbackResponse(rw, req, errDebugAccessDenied)
+				return
+			}
+
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
+
+// debugCapture keeps at most maxDebugLogLines log lines of the request of maxDebugLogBytes in total
+type debugCapture struct {
+	mu      sync.Mutex
+	lines   []string
+	size    int
+	dropped int
+	// written is a count of lines and reported is a count of dropped lines already added to the response
+	written  int
+	reported int
+}
+
+func (capture *debugCapture) Write(p []byte) (int, error) {
+	capture.mu.Lock()
+	defer capture.mu.Unlock()
+
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		if len(capture.lines) >= maxDebugLogLines || capture.size >= maxDebugLogBytes {
+			capture.dropped++
+			continue
+		}
+
+		// header values could not contain control characters
+		line = strings.Map(func(r rune) rune {
+			if r < ' ' || r == 0x7f {
+				return ' '
+			}
+			return r
+		}, line)
+
+		if len(line) > maxDebugLogLineLength {
+			line = line[:maxDebugLogLineLength]
+		}
+
+		if capture.size+len(line) > maxDebugLogBytes {
+			capture.size = maxDebugLogBytes
+			capture.dropped++
+			continue
+		}
+
+		capture.size += len(line)
+		capture.lines = append(capture.lines, line)
+	}
+
+	return len(p), nil
+}
+
+// writeLines adds lines captured since the previous call to header with the key
+// followed by a count of lines dropped since then
+func (capture *debugCapture) writeLines(header http.Header, key string) {
+	capture.mu.Lock()
+	defer capture.mu.Unlock()
+
+	for _, line := range capture.lines[capture.written:] {
+		header.Add(key, line)
+	}
+	capture.written = len(capture.lines)
+
+	if capture.dropped > capture.reported {
+		header.Add(key, fmt.Sprintf("%d more lines are dropped", capture.dropped-capture.reported))
+		capture.reported = capture.dropped
+	}
+}
+
+// debugWriter adds captured logs to headers before the response is written
+type debugWriter struct {
+	http.ResponseWriter
+	capture     *debugCapture
+	wroteHeader bool
+}
+
+func (dw *debugWriter) WriteHeader(code int) {
+	if !dw.wroteHeader {
+		dw.wroteHeader = true
+		dw.capture.writeLines(dw.Header(), debugLogHeader)
+	}
+	dw.ResponseWriter.WriteHeader(code)
+}
+
+// finish adds lines logged after the headers are written to trailers
+func (dw *debugWriter) finish() {
+	if !dw.wroteHeader {
+		dw.capture.writeLines(dw.Header(), debugLogHeader)
+		return
+	}
+	dw.capture.writeLines(dw.Header(), http.TrailerPrefix+debugLogHeader)
+}
+
+func (dw *debugWriter) Write(data []byte) (int, error) {
+	if !dw.wroteHeader {
+		dw.WriteHeader(http.StatusOK)
+	}
+	return dw.ResponseWriter.Write(data)
+}
+
+func (dw *debugWriter) Flush() {
+	if flusher, ok := dw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,121 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type DebugSuite struct{}
+
+var _ = Suite(&DebugSuite{})
+
+func (suite *DebugSuite) TestAllowed(c *C) {
+	access, err := newDebugAccess("secret", []string{"10.0.0.0/8", "::1/128"})
+	c.Assert(err, IsNil)
+
+	noSecret, err := newDebugAccess("", nil)
+	c.Assert(err, IsNil)
+
+	for _, tc := range []struct {
+		access     *debugAccess
+		remoteAddr string
+		secret     string
+		allowed    bool
+	}{
+		{access, "192.168.1.1:1234", "secret", true},
+		{access, "192.168.1.1:1234", "wrong", false},
+		{access, "192.168.1.1:1234", "", false},
+		// wrong secret is denied even from trusted network
+		{access, "10.1.2.3:1234", "wrong", false},
+		{access, "10.1.2.3:1234", "", true},
+		{access, "[::1]:1234", "", true},
+		{access, "10.1.2.3", "", true},
+		{access, "192.168.1.1", "", false},
+		{access, "invalid", "", false},
+		// nothing is allowed without secret and networks
+		{noSecret, "127.0.0.1:1234", "", false},
+		{noSecret, "127.0.0.1:1234", "secret", false},
+	} {
+		req := httptest.NewRequest("GET", "/requestcount?debug", nil)
+		req.RemoteAddr = tc.remoteAddr
+		if tc.secret != "" {
+			req.Header.Set(debugSecretHeader, tc.secret)
+		}
+
+		c.Assert(tc.access.Allowed(req), Equals, tc.allowed, Commentf("remote addr: %s, secret: %s", tc.remoteAddr, tc.secret))
+	}
+
+	_, err = newDebugAccess("", []string{"10.0.0.0"})
+	c.Assert(err, ErrorMatches, "error parse debug trusted network: .*")
+}
+
+// serveInline serves request with debug=inline by handler logging before lines to the capture,
+// writing the response and then logging after lines
+func (suite *DebugSuite) serveInline(c *C, before, after []string) *http.Response {
+	access, err := newDebugAccess("secret", nil)
+	c.Assert(err, IsNil)
+	app := &Application{debugAccess: access}
+
+	handler := app.debugRequests()(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		debug, ok := getDebugRequest(req.Context())
+		c.Assert(ok, Equals, true)
+		c.Assert(debug.capture, NotNil)
+
+		for _, line := range before {
+			debug.capture.Write([]byte(line + "\n"))
+		}
+		rw.Write([]byte("1"))
+		for _, line := range after {
+			debug.capture.Write([]byte(line + "\n"))
+		}
+	}))
+
+	req := httptest.NewRequest("GET", "/requestcount?debug=inline", nil)
+	req.Header.Set(debugSecretHeader, "secret")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	return recorder.Result()
+}
+
+func (suite *DebugSuite) TestInlineLogs(c *C) {
+	resp := suite.serveInline(c, []string{"first", "second\twith tab"}, []string{"late"})
+
+	c.Assert(resp.Header[debugLogHeader], DeepEquals, []string{"first", "second with tab"})
+	c.Assert(resp.Trailer[debugLogHeader], DeepEquals, []string{"late"})
+}
+
+func (suite *DebugSuite) TestInlineLogsLimit(c *C) {
+	line := strings.Repeat("a", 1000)
+
+	var before []string
+	for i := 0; i < 20; i++ {
+		before = append(before, line)
+	}
+	resp := suite.serveInline(c, before, []string{"late"})
+
+	// lines fit into maxDebugLogBytes and the last value tells how many are dropped
+	lines := resp.Header[debugLogHeader]
+	c.Assert(lines, HasLen, 9)
+	c.Assert(lines[:8], DeepEquals, before[:8])
+	c.Assert(lines[8], Equals, "12 more lines are dropped")
+
+	c.Assert(resp.Trailer[debugLogHeader], DeepEquals, []string{"1 more lines are dropped"})
+}
+
+func (suite *DebugSuite) TestInlineLogsLineCount(c *C) {
+	var before []string
+	for i := 0; i < maxDebugLogLines+5; i++ {
+		before = append(before, fmt.Sprintf("line %d", i))
+	}
+	resp := suite.serveInline(c, before, nil)
+
+	lines := resp.Header[debugLogHeader]
+	c.Assert(lines, HasLen, maxDebugLogLines+1)
+	c.Assert(lines[maxDebugLogLines], Equals, "5 more lines are dropped")
+	c.Assert(resp.Trailer[debugLogHeader], IsNil)
+}
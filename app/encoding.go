@@ -0,0 +1,105 @@
+package app
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/THE108/requestcounter/utils/codec"
+	"github.com/THE108/requestcounter/utils/errors"
+	"github.com/THE108/requestcounter/utils/params"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	formatUrlParamName = "format"
+	prettyUrlParamName = "pretty"
+)
+
+type responseFormat struct {
+	encoder *codec.Encoder
+	pretty  bool
+}
+
+var defaultResponseFormat = &responseFormat{encoder: codec.JSON}
+
+type responseFormatKey struct{}
+
+// negotiate selects response encoder by format param or Accept header and stores it to the context.
+// Returns 406 error if no encoder is acceptable.
+func (this *Application) negotiate(ctx context.Context, req *http.Request) (context.Context, error) {
+	params := params.NewParams(req)
+
+	format, err := params.String(formatUrlParamName, false)
+	if err != nil {
+		return ctx, errors.Wrap(err, http.StatusBadRequest)
+	}
+
+	pretty, err := params.Bool(prettyUrlParamName, false, false)
+	if err != nil {
+		return ctx, errors.Wrap(err, http.StatusBadRequest)
+	}
+
+	encoder, ok := codec.Negotiate(req.Header.Get("Accept"), format)
+	if !ok {
+		return ctx, errors.New(http.StatusNotAcceptable, "none of acceptable formats is supported, supported are: "+codec.ContentTypes())
+	}
+
+	return context.WithValue(ctx, responseFormatKey{}, &responseFormat{encoder: encoder, pretty: pretty}), nil
+}
+
+func getResponseFormat(ctx context.Context) *responseFormat {
+	if format, ok := ctx.Value(responseFormatKey{}).(*responseFormat); ok {
+		return format
+	}
+	return defaultResponseFormat
+}
+
+// encode encodes data in the negotiated format, errors are encoded as JSON if the format could not represent them.
+// Returns 406 error if data could not be represented in the format.
+func (format *responseFormat) encode(data interface{}) ([]byte, string, error) {
+	encoder := format.encoder
+
+	response, err := encoder.Encode(data, format.pretty)
+	if err == codec.ErrUnsupported {
+		if _, isErr := data.(error); !isErr {
+			return nil, "", notRepresentable(encoder)
+		}
+
+		encoder = codec.JSON
+		response, err = encoder.Encode(data, format.pretty)
+	}
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	contentType := encoder.ContentType
+	if _, isErr := data.(*errors.CodedError); isErr && encoder == codec.JSON {
+		contentType = errors.ProblemContentType
+	}
+
+	if strings.HasPrefix(contentType, "text/") {
+		contentType += "; charset=utf-8"
+	}
+
+	return response, contentType, nil
+}
+
+// checkOutput returns 406 error if the handler declares output that could not be represented in the negotiated format
+func checkOutput(ctx context.Context, handler interface{}) error {
+	getter, ok := handler.(IOutputGetter)
+	if !ok {
+		return nil
+	}
+
+	encoder := getResponseFormat(ctx).encoder
+	if !encoder.Supports(getter.GetOutput()) {
+		return notRepresentable(encoder)
+	}
+	return nil
+}
+
+func notRepresentable(encoder *codec.Encoder) error {
+	return errors.New(http.StatusNotAcceptable, "response could not be represented as "+encoder.ContentType)
+}
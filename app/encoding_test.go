@@ -0,0 +1,56 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/THE108/requestcounter/utils/errors"
+
+	. "gopkg.in/check.v1"
+	"golang.org/x/net/context"
+)
+
+type EncodingSuite struct{}
+
+var _ = Suite(&EncodingSuite{})
+
+type plainTextOutput struct{}
+
+func (plainTextOutput) PlainText() string {
+	return "1"
+}
+
+type plainTextHandler struct{}
+
+func (plainTextHandler) GetOutput() interface{} {
+	return plainTextOutput{}
+}
+
+func (suite *EncodingSuite) TestCheckOutput(c *C) {
+	app := &Application{}
+
+	for _, tc := range []struct {
+		accept  string
+		handler interface{}
+		code    int
+	}{
+		{"text/plain", plainTextHandler{}, 0},
+		{"application/json", plainTextHandler{}, 0},
+		{"text/csv", plainTextHandler{}, http.StatusNotAcceptable},
+		// output of handlers not declaring it is checked only when encoded
+		{"text/csv", struct{}{}, 0},
+	} {
+		req := httptest.NewRequest("GET", "/requestcount", nil)
+		req.Header.Set("Accept", tc.accept)
+
+		ctx, err := app.negotiate(context.Background(), req)
+		c.Assert(err, IsNil)
+
+		err = checkOutput(ctx, tc.handler)
+		if tc.code == 0 {
+			c.Assert(err, IsNil, Commentf("accept: %s", tc.accept))
+		} else {
+			c.Assert(errors.From(err).GetHttpCode(), Equals, tc.code, Commentf("accept: %s", tc.accept))
+		}
+	}
+}
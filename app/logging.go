@@ -0,0 +1,158 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/THE108/requestcounter/handlers/admin"
+	"github.com/THE108/requestcounter/utils/closer"
+	"github.com/THE108/requestcounter/utils/log"
+	"github.com/THE108/requestcounter/utils/rotate"
+)
+
+// initLogging creates log levels and output of loggers, the application logger is created even on error.
+// Outputs are closed by the log closer after everything else so logs written on closing are not lost.
+func (this *Application) initLogging() error {
+	this.initLogLevels()
+	this.logEncoder = log.NewEncoder(this.config.LogFormat, this.config.LogTraceFields)
+	this.logOutput = os.Stderr
+	this.logger = this.newLogger("Application")
+	this.logCloser = closer.NewCloser()
+
+	this.closer.OnReopen(func(err error) {
+		if err != nil {
+			this.logger.Error("error reopen log files:", err.Error())
+			return
+		}
+		this.logger.Info("log files are reopened")
+	})
+
+	output, err := this.newLogOutput()
+	if err != nil {
+		return err
+	}
+
+	base := output
+	if this.config.LogAsync {
+		this.logAsync, err = log.NewAsyncWriter(&log.AsyncConfig{
+			Out:       output,
+			QueueSize: this.config.LogQueueSize,
+			Policy:    this.config.LogOverflowPolicy,
+		})
+		if err != nil {
+			return fmt.Errorf("error init async log: %s", err.Error())
+		}
+
+		// queued messages are written on closing, messages logged later are written synchronously
+		this.logCloser.AddCloser(this.logAsync)
+		output = this.logAsync
+	}
+
+	// the output is closed after queued messages are written to it, stderr is left open
+	if outputCloser, ok := base.(closer.ICloser); ok && base != io.Writer(os.Stderr) {
+		this.logCloser.AddCloser(outputCloser)
+	}
+
+	// recent entries are kept in memory regardless of the output queue
+	if this.config.LogRingSize > 0 {
+		this.logRing = log.NewRing(this.config.LogRingSize, this.config.LogRingEntrySize)
+		output = log.NewTee(output, this.logRing)
+	}
+
+	this.logOutput = output
+	this.logger = this.newLogger("Application")
+
+	return nil
+}
+
+// newLogOutput creates the configured output of logs
+func (this *Application) newLogOutput() (io.Writer, error) {
+	switch this.config.LogOutput {
+	case "stderr":
+		return os.Stderr, nil
+
+	case "file":
+		writer, err := this.newRotateWriter(&rotate.Config{
+			Filename:   this.config.LogFile,
+			MaxSize:    this.config.LogFileMaxSize,
+			MaxAge:     this.config.LogFileMaxAge,
+			MaxBackups: this.config.LogFileMaxBackups,
+			Compress:   this.config.LogFileCompress,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error open log file: %s", err.Error())
+		}
+		return writer, nil
+
+	case "syslog":
+		facility, err := log.ParseFacility(this.config.LogSyslogFacility)
+		if err != nil {
+			return nil, err
+		}
+
+		return log.NewSyslogWriter(&log.SyslogConfig{
+			Network:  this.config.LogSyslogNetwork,
+			Address:  this.config.LogSyslogAddress,
+			Facility: facility,
+			Tag:      this.config.LogSyslogTag,
+		})
+
+	case "journald":
+		return log.NewJournalWriter(&log.JournalConfig{
+			Socket:     this.config.LogJournalSocket,
+			Identifier: this.config.LogSyslogTag,
+		})
+
+	default:
+		return nil, fmt.Errorf("unknown log output %q", this.config.LogOutput)
+	}
+}
+
+// newRotateWriter opens file reopened on SIGUSR1, errors of compression are logged
+func (this *Application) newRotateWriter(cfg *rotate.Config) (*rotate.Writer, error) {
+	writer, err := rotate.NewWriter(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	writer.OnError = func(err error) {
+		this.logger.Error("error rotate file:", err.Error())
+	}
+	this.closer.AddReopener(writer)
+
+	return writer, nil
+}
+
+// getLogRing returns ring of recent logs or nil if it is disabled
+func (this *Application) getLogRing() admin.ILogRing {
+	if this.logRing == nil {
+		return nil
+	}
+	return this.logRing
+}
+
+// closeLogging writes queued logs and closes log files
+func (this *Application) closeLogging() {
+	if this.logCloser == nil {
+		return
+	}
+
+	if err := this.logCloser.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, "error close log:", err.Error())
+	}
+}
+
+// initLogLevels creates levels of handlers and components, levels not set in config follow log-level
+func (this *Application) initLogLevels() {
+	this.logLevels = log.NewLevels(this.config.LogLevel)
+	for name, level := range this.config.LogLevels {
+		this.logLevels.Level(name).Set(level)
+	}
+}
+
+// newLogger creates logger of handler or component name writing in the configured format,
+// its level could be changed at runtime
+func (this *Application) newLogger(name string) log.ILogger {
+	return log.NewWithLevel(this.logOutput, name, this.logLevels.Level(name), this.logEncoder)
+}
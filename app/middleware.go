@@ -0,0 +1,294 @@
+package app
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/THE108/requestcounter/utils/errors"
+
+	"golang.org/x/net/context"
+)
+
+// Middleware wraps handler adding some behaviour around it
+type Middleware func(next http.Handler) http.Handler
+
+func init() {
+	errors.Register(context.DeadlineExceeded, http.StatusGatewayTimeout, "timeout")
+}
+
+// chain wraps handler with middlewares, the first one is the outermost
+func chain(handler http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// initMiddlewares builds the global chain wrapping the router
+func (this *Application) initMiddlewares() {
+	this.middlewares = []Middleware{TraceData(this.tracePropagator, this.config.TraceResponseHeader)}
+
+	if this.accessLogger != nil {
+		this.middlewares = append(this.middlewares, AccessLog(this.accessLogger))
+	}
+
+	this.middlewares = append(this.middlewares, this.debugRequests(), this.recoverPanic())
+
+	if len(this.config.CORSAllowedOrigins) > 0 {
+		this.middlewares = append(this.middlewares, CORS(&CORSConfig{
+			AllowedOrigins: this.config.CORSAllowedOrigins,
+			AllowedMethods: this.config.CORSAllowedMethods,
+			AllowedHeaders: this.config.CORSAllowedHeaders,
+			MaxAge:         this.config.CORSMaxAge,
+		}))
+	}
+
+	if this.config.Compression {
+		this.middlewares = append(this.middlewares, Compress())
+	}
+
+	this.middlewares = append(this.middlewares, MaxBodySize(this.config.MaxBodySize))
+}
+
+// recoverPanic responds with 500 if handler panics before the response is written.
+// http.ErrAbortHandler is passed on to abort the response silently.
+func (this *Application) recoverPanic() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			rw := newResponseWriter(w)
+			defer func() {
+				r := recover()
+				if r == nil {
+					return
+				}
+				if r == http.ErrAbortHandler {
+					panic(r)
+				}
+
+				this.logger.Errorf("panic serving %s %s: %v\n%s", req.Method, req.RequestURI, r, debug.Stack())
+
+				// headers are already sent, the response could not be replaced
+				if rw.status != 0 {
+					return
+				}
+				this.writeFallbackResponse(rw, req, errors.New(http.StatusInternalServerError, "internal server error"))
+			}()
+
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
+
+// Timeout sets deadline to the request context,
+// handlers returning context error after the deadline respond with 504
+func Timeout(timeout time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			ctx, cancel := context.WithTimeout(req.Context(), timeout)
+			defer cancel()
+
+			next.ServeHTTP(rw, req.WithContext(ctx))
+		})
+	}
+}
+
+type CORSConfig struct {
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	MaxAge         time.Duration
+}
+
+// CORS adds CORS headers for allowed origins ("*" allows any) and responds to preflight requests
+func CORS(cfg *CORSConfig) Middleware {
+	methods := strings.Join(cfg.AllowedMethods, ", ")
+	headers := strings.Join(cfg.AllowedHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge.Seconds()))
+
+	allowed := func(origin string) bool {
+		for _, allowedOrigin := range cfg.AllowedOrigins {
+			if allowedOrigin == "*" || allowedOrigin == origin {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			origin := req.Header.Get("Origin")
+			if origin == "" || !allowed(origin) {
+				next.ServeHTTP(rw, req)
+				return
+			}
+
+			header := rw.Header()
+			header.Set("Access-Control-Allow-Origin", origin)
+			header.Add("Vary", "Origin")
+			header.Set("Access-Control-Expose-Headers", "ETag")
+
+			if req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != "" {
+				header.Set("Access-Control-Allow-Methods", methods)
+				header.Set("Access-Control-Allow-Headers", headers)
+				header.Set("Access-Control-Max-Age", maxAge)
+				rw.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
+
+// Compress compresses responses with gzip or deflate if the client accepts it.
+// Event streams and responses without body are not compressed.
+func Compress() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			encoding := acceptedEncoding(req.Header.Get("Accept-Encoding"))
+			if encoding == "" {
+				next.ServeHTTP(rw, req)
+				return
+			}
+
+			rw.Header().Add("Vary", "Accept-Encoding")
+
+			cw := &compressWriter{ResponseWriter: rw, encoding: encoding}
+			defer cw.Close()
+
+			next.ServeHTTP(cw, req)
+		})
+	}
+}
+
+// acceptedEncoding returns gzip or deflate if it is listed in Accept-Encoding with non-zero quality
+func acceptedEncoding(acceptEncoding string) string {
+	var deflate bool
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.TrimSpace(fields[0])
+		if len(fields) > 1 && strings.Replace(strings.TrimSpace(fields[1]), " ", "", -1) == "q=0" {
+			continue
+		}
+
+		switch name {
+		case "gzip":
+			return "gzip"
+		case "deflate":
+			deflate = true
+		}
+	}
+
+	if deflate {
+		return "deflate"
+	}
+	return ""
+}
+
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	writer      io.WriteCloser
+	wroteHeader bool
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+
+	header := cw.Header()
+	compressible := code != http.StatusNoContent && code != http.StatusNotModified &&
+		header.Get("Content-Encoding") == "" &&
+		!strings.HasPrefix(header.Get("Content-Type"), "text/event-stream")
+
+	if compressible {
+		header.Set("Content-Encoding", cw.encoding)
+		header.Del("Content-Length")
+
+		if cw.encoding == "gzip" {
+			cw.writer = gzip.NewWriter(cw.ResponseWriter)
+		} else {
+			cw.writer, _ = flate.NewWriter(cw.ResponseWriter, flate.DefaultCompression)
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *compressWriter) Write(data []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if cw.writer == nil {
+		return cw.ResponseWriter.Write(data)
+	}
+	return cw.writer.Write(data)
+}
+
+func (cw *compressWriter) Flush() {
+	if flusher, ok := cw.writer.(interface {
+		Flush() error
+	}); ok {
+		flusher.Flush()
+	}
+
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (cw *compressWriter) Close() error {
+	if cw.writer == nil {
+		return nil
+	}
+	return cw.writer.Close()
+}
+
+// MaxBodySize limits size of request body, reading more than limit fails with 413.
+// Nested limits could only make the limit smaller.
+func MaxBodySize(limit int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if req.Body != nil {
+				req.Body = &limitedBody{ReadCloser: req.Body, remaining: limit}
+			}
+
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
+
+var errBodyTooLarge = errors.New(http.StatusRequestEntityTooLarge, "request body is too large").WithReason("body_too_large")
+
+// limitedBody fails with errBodyTooLarge once more than remaining bytes are read
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (lb *limitedBody) Read(p []byte) (int, error) {
+	if lb.remaining < 0 {
+		return 0, errBodyTooLarge
+	}
+
+	if int64(len(p)) > lb.remaining+1 {
+		p = p[:lb.remaining+1]
+	}
+
+	n, err := lb.ReadCloser.Read(p)
+	lb.remaining -= int64(n)
+	if lb.remaining < 0 {
+		return 0, errBodyTooLarge
+	}
+
+	return n, err
+}
@@ -0,0 +1,229 @@
+package app
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/THE108/requestcounter/utils/errors"
+	"github.com/THE108/requestcounter/utils/log"
+	"github.com/THE108/requestcounter/utils/tracedata"
+
+	. "gopkg.in/check.v1"
+)
+
+type MiddlewareSuite struct {
+	app *Application
+}
+
+var _ = Suite(&MiddlewareSuite{})
+
+func (suite *MiddlewareSuite) SetUpTest(c *C) {
+	propagator, err := tracedata.NewPropagator(tracedata.DefaultFormats)
+	c.Assert(err, IsNil)
+
+	suite.app = &Application{
+		logger:          log.NewDevNullLogger(),
+		logEncoder:      log.NewEncoder("text", false),
+		logOutput:       ioutil.Discard,
+		logLevels:       log.NewLevels(log.ERROR),
+		tracePropagator: propagator,
+	}
+}
+
+func serve(handler http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func (suite *MiddlewareSuite) TestRecoverPanic(c *C) {
+	handler := suite.app.recoverPanic()(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		panic("boom")
+	}))
+
+	recorder := serve(handler, httptest.NewRequest("GET", "/requestcount", nil))
+	c.Assert(recorder.Code, Equals, http.StatusInternalServerError)
+	c.Assert(recorder.Header().Get("Content-Type"), Equals, errors.ProblemContentType)
+
+	var problem map[string]interface{}
+	c.Assert(json.Unmarshal(recorder.Body.Bytes(), &problem), IsNil)
+	c.Assert(problem["status"], Equals, float64(http.StatusInternalServerError))
+}
+
+func (suite *MiddlewareSuite) TestRecoverPanicAfterHeaders(c *C) {
+	handler := suite.app.recoverPanic()(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte("partial"))
+		panic("boom")
+	}))
+
+	recorder := serve(handler, httptest.NewRequest("GET", "/requestcount", nil))
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), Equals, "partial")
+}
+
+func (suite *MiddlewareSuite) TestRecoverPanicAbortHandler(c *C) {
+	handler := suite.app.recoverPanic()(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	recorder := httptest.NewRecorder()
+	func() {
+		defer func() {
+			c.Assert(recover(), Equals, http.ErrAbortHandler)
+		}()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/requestcount", nil))
+	}()
+	c.Assert(recorder.Body.Len(), Equals, 0)
+}
+
+func (suite *MiddlewareSuite) TestTimeout(c *C) {
+	handler := Timeout(10 * time.Millisecond)(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		ctx := suite.app.createContext("test", req)
+		<-ctx.Done()
+		suite.app.writeResponse(ctx, rw, ctx.Err())
+	}))
+
+	recorder := serve(handler, httptest.NewRequest("GET", "/requestcount", nil))
+	c.Assert(recorder.Code, Equals, http.StatusGatewayTimeout)
+	c.Assert(recorder.Body.String(), Matches, `.*"timeout".*`)
+}
+
+func (suite *MiddlewareSuite) TestCORS(c *C) {
+	handler := CORS(&CORSConfig{
+		AllowedOrigins: []string{"http://allowed.example"},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Content-Type"},
+		MaxAge:         time.Hour,
+	})(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte("1"))
+	}))
+
+	preflight := httptest.NewRequest("OPTIONS", "/requestcount", nil)
+	preflight.Header.Set("Origin", "http://allowed.example")
+	preflight.Header.Set("Access-Control-Request-Method", "POST")
+
+	recorder := serve(handler, preflight)
+	c.Assert(recorder.Code, Equals, http.StatusNoContent)
+	c.Assert(recorder.Header().Get("Access-Control-Allow-Origin"), Equals, "http://allowed.example")
+	c.Assert(recorder.Header().Get("Access-Control-Allow-Methods"), Equals, "GET, POST")
+	c.Assert(recorder.Header().Get("Access-Control-Allow-Headers"), Equals, "Content-Type")
+	c.Assert(recorder.Header().Get("Access-Control-Max-Age"), Equals, "3600")
+	c.Assert(recorder.Body.Len(), Equals, 0)
+
+	req := httptest.NewRequest("GET", "/requestcount", nil)
+	req.Header.Set("Origin", "http://allowed.example")
+	recorder = serve(handler, req)
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Access-Control-Allow-Origin"), Equals, "http://allowed.example")
+	c.Assert(recorder.Header().Get("Access-Control-Expose-Headers"), Equals, "ETag")
+
+	// disallowed origin is served without CORS headers
+	disallowed := httptest.NewRequest("OPTIONS", "/requestcount", nil)
+	disallowed.Header.Set("Origin", "http://other.example")
+	disallowed.Header.Set("Access-Control-Request-Method", "POST")
+	recorder = serve(handler, disallowed)
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Access-Control-Allow-Origin"), Equals, "")
+	c.Assert(recorder.Header().Get("Access-Control-Allow-Methods"), Equals, "")
+}
+
+func (suite *MiddlewareSuite) TestCompress(c *C) {
+	body := strings.Repeat("requestcount ", 100)
+	handler := Compress()(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/plain")
+		rw.Write([]byte(body))
+	}))
+
+	for _, tc := range []struct {
+		acceptEncoding string
+		encoding       string
+	}{
+		{"gzip", "gzip"},
+		{"deflate", "deflate"},
+		{"deflate, gzip", "gzip"},
+		{"gzip;q=0, deflate", "deflate"},
+		{"br", ""},
+		{"", ""},
+	} {
+		req := httptest.NewRequest("GET", "/requestcount", nil)
+		req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+		recorder := serve(handler, req)
+
+		comment := Commentf("accept encoding: %s", tc.acceptEncoding)
+		c.Assert(recorder.Header().Get("Content-Encoding"), Equals, tc.encoding, comment)
+
+		var reader io.Reader = recorder.Body
+		switch tc.encoding {
+		case "gzip":
+			gzipReader, err := gzip.NewReader(recorder.Body)
+			c.Assert(err, IsNil, comment)
+			reader = gzipReader
+		case "deflate":
+			reader = flate.NewReader(recorder.Body)
+		}
+
+		decoded, err := ioutil.ReadAll(reader)
+		c.Assert(err, IsNil, comment)
+		c.Assert(string(decoded), Equals, body, comment)
+	}
+}
+
+func (suite *MiddlewareSuite) TestCompressExcluded(c *C) {
+	for _, tc := range []struct {
+		contentType string
+		code        int
+	}{
+		{"text/event-stream", http.StatusOK},
+		{"text/plain", http.StatusNotModified},
+		{"text/plain", http.StatusNoContent},
+	} {
+		handler := Compress()(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set("Content-Type", tc.contentType)
+			rw.WriteHeader(tc.code)
+			if tc.code == http.StatusOK {
+				rw.Write([]byte("data: 1\n\n"))
+			}
+		}))
+
+		req := httptest.NewRequest("GET", "/requestcount", nil)
+		req.Header.Set("Accept-Encoding", "gzip, deflate")
+		recorder := serve(handler, req)
+
+		comment := Commentf("content type: %s, code: %d", tc.contentType, tc.code)
+		c.Assert(recorder.Code, Equals, tc.code, comment)
+		c.Assert(recorder.Header().Get("Content-Encoding"), Equals, "", comment)
+		if tc.code == http.StatusOK {
+			c.Assert(recorder.Body.String(), Equals, "data: 1\n\n", comment)
+		} else {
+			c.Assert(recorder.Body.Len(), Equals, 0, comment)
+		}
+	}
+}
+
+func (suite *MiddlewareSuite) TestMaxBodySize(c *C) {
+	handler := MaxBodySize(4)(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		ctx := suite.app.createContext("test", req)
+		data, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			suite.app.writeResponse(ctx, rw, err)
+			return
+		}
+		rw.Write(data)
+	}))
+
+	recorder := serve(handler, httptest.NewRequest("POST", "/requestcount", bytes.NewBufferString("1234")))
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), Equals, "1234")
+
+	recorder = serve(handler, httptest.NewRequest("POST", "/requestcount", bytes.NewBufferString("12345")))
+	c.Assert(recorder.Code, Equals, http.StatusRequestEntityTooLarge)
+	c.Assert(recorder.Body.String(), Matches, `.*"body_too_large".*`)
+}
@@ -1,32 +1,121 @@
 package app
 
 import (
+	"github.com/THE108/requestcounter/models/alerting"
 	"github.com/THE108/requestcounter/models/requestcount"
+	"github.com/THE108/requestcounter/utils/archive"
+	"github.com/THE108/requestcounter/utils/metrics"
 )
 
 type models struct {
 	requestCounter requestcount.IRequestCounter
+	alerting       *alerting.Manager
 }
 
 func (this *Application) initModels() error {
+	var archiveConfig *archive.Config
+	if this.config.Archive {
+		archiveConfig = &archive.Config{
+			Dir:            this.config.ArchiveDir,
+			SegmentSize:    this.config.ArchiveSegmentSize,
+			SegmentAge:     this.config.ArchiveSegmentAge,
+			RetentionCount: this.config.ArchiveRetentionCount,
+			RetentionAge:   this.config.ArchiveRetentionAge,
+		}
+	}
+
+	var anomalyConfig *requestcount.AnomalyConfig
+	if this.config.AnomalyDetection {
+		anomalyConfig = &requestcount.AnomalyConfig{
+			Method:    this.config.AnomalyMethod,
+			Window:    this.config.AnomalyWindow,
+			Threshold: this.config.AnomalyThreshold,
+			MaxEvents: this.config.AnomalyMaxEvents,
+		}
+	}
+
 	counter := requestcount.NewRequestCounter(&requestcount.RequestCounterConfig{
 		IntervalCount:    this.config.IntervalCount,
 		IntervalDuration: this.config.IntervalDuration,
 		Filename:         this.config.Filename,
 		Persistent:       this.config.Persistent,
 		PersistDuration:  this.config.PersistDuration,
-		Logger:           this.logger,
+		FutureTolerance:  this.config.EventsFutureTolerance,
+		MaxWeightedKeys:  this.config.WeightedMaxKeys,
+		MaxSeries:        this.config.SeriesMaxCount,
+		ValueCounters:    this.config.ValueCounters,
+		Archive:          archiveConfig,
+		Anomaly:          anomalyConfig,
+		MaxSubscribers:   this.config.StreamMaxSubscribers,
+		Tracer:           this.tracer,
+		Logger:           this.newLogger("RequestCounter"),
 	})
 
+	alertManager, err := this.initAlerting(counter)
+	if err != nil {
+		return err
+	}
+
 	this.closer.AddCloser(counter)
 
 	if err := counter.Run(); err != nil {
 		return err
 	}
 
+	this.closer.AddCloser(alertManager)
+
+	if err := alertManager.Run(); err != nil {
+		return err
+	}
+
 	this.models = models{
 		requestCounter: counter,
+		alerting:       alertManager,
 	}
 
 	return nil
 }
+
+// metricsCollectors returns sources of metrics written by /metrics
+func (this *Application) metricsCollectors() []metrics.ICollector {
+	collectors := []metrics.ICollector{this.models.requestCounter}
+	if this.logAsync != nil {
+		collectors = append(collectors, this.logAsync)
+	}
+	if this.tracer != nil {
+		collectors = append(collectors, this.tracer)
+	}
+	return collectors
+}
+
+func (this *Application) initAlerting(counter requestcount.IRequestCounter) (*alerting.Manager, error) {
+	rules := make([]alerting.Rule, 0, len(this.config.AlertRules))
+	for _, r := range this.config.AlertRules {
+		rules = append(rules, alerting.Rule{
+			Name:       r.Name,
+			Counter:    r.Counter,
+			Window:     r.Window,
+			Comparator: r.Comparator,
+			Threshold:  r.Threshold,
+			For:        r.For,
+			Webhook:    r.Webhook,
+		})
+	}
+
+	manager, err := alerting.NewManager(&alerting.Config{
+		Rules:          rules,
+		Source:         counter,
+		MaxAttempts:    this.config.AlertMaxAttempts,
+		InitialBackoff: this.config.AlertBackoff,
+		MaxBackoff:     this.config.AlertMaxBackoff,
+		Timeout:        this.config.AlertTimeout,
+		Logger:         this.newLogger("Alerting"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	counter.AddShiftListener(manager)
+
+	return manager, nil
+}
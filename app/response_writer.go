@@ -0,0 +1,61 @@
+package app
+
+import (
+	"net/http"
+)
+
+// responseWriter records status code and size of the response
+type responseWriter struct {
+	http.ResponseWriter
+	status   int
+	size     int
+	panicked bool
+}
+
+func newResponseWriter(rw http.ResponseWriter) *responseWriter {
+	return &responseWriter{
+		ResponseWriter: rw,
+	}
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+	if rw.status == 0 {
+		rw.status = code
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *responseWriter) Write(data []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	n, err := rw.ResponseWriter.Write(data)
+	rw.size += n
+	return n, err
+}
+
+func (rw *responseWriter) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// markPanic must be deferred by handler, it records the panic so the response is observed as 500
+// even though recoverPanic writes it after the handler returns, the panic goes on to recoverPanic
+func (rw *responseWriter) markPanic() {
+	if r := recover(); r != nil {
+		rw.panicked = true
+		panic(r)
+	}
+}
+
+// Status returns written status code, 500 if handler panicked
+func (rw *responseWriter) Status() int {
+	if rw.panicked {
+		return http.StatusInternalServerError
+	}
+	if rw.status == 0 {
+		return http.StatusOK
+	}
+	return rw.status
+}
@@ -0,0 +1,46 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+type ResponseWriterSuite struct{}
+
+var _ = Suite(&ResponseWriterSuite{})
+
+// Hook up gocheck into the "go test" runner.
+func TestStart(t *testing.T) {
+	TestingT(t)
+}
+
+func (suite *ResponseWriterSuite) TestStatus(c *C) {
+	rw := newResponseWriter(httptest.NewRecorder())
+	c.Assert(rw.Status(), Equals, http.StatusOK)
+
+	rw.WriteHeader(http.StatusNotModified)
+	rw.WriteHeader(http.StatusOK)
+	c.Assert(rw.Status(), Equals, http.StatusNotModified)
+}
+
+func (suite *ResponseWriterSuite) TestPanicIsObservedAs500(c *C) {
+	var status int
+	rw := newResponseWriter(httptest.NewRecorder())
+
+	func() {
+		defer func() {
+			c.Assert(recover(), Equals, "boom")
+		}()
+		defer func() {
+			status = rw.Status()
+		}()
+		defer rw.markPanic()
+
+		panic("boom")
+	}()
+
+	c.Assert(status, Equals, http.StatusInternalServerError)
+}
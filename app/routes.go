@@ -1,10 +1,16 @@
 package app
 
 import (
+	"github.com/THE108/requestcounter/handlers/admin"
+	"github.com/THE108/requestcounter/handlers/alerting"
+	"github.com/THE108/requestcounter/handlers/metrics"
 	"github.com/THE108/requestcounter/handlers/requestcount"
 )
 
 func (this *Application) getHandlers() []*HandlerInfo {
+	timeout := Timeout(this.config.RequestTimeout)
+	debugOnly := this.requireDebugAccess()
+
 	return []*HandlerInfo{
 		{
 			Name:    "GetRequestCount",
@@ -12,5 +18,125 @@ func (this *Application) getHandlers() []*HandlerInfo {
 			Route:   "/requestcount",
 			Handler: requestcount.NewGetRecipeHandler(this.models.requestCounter),
 		},
+		{
+			Name:        "GetRequestCountCurrent",
+			Method:      GET,
+			Route:       "/requestcount/current",
+			Handler:     requestcount.NewGetCurrentHandler(this.models.requestCounter),
+			Middlewares: []Middleware{timeout},
+		},
+		{
+			Name:    "GetRequestCountStream",
+			Method:  GET,
+			Route:   "/requestcount/stream",
+			Handler: requestcount.NewGetStreamHandler(this.models.requestCounter),
+		},
+		{
+			Name:        "GetRequestCountHistory",
+			Method:      GET,
+			Route:       "/requestcount/history",
+			Handler:     requestcount.NewGetHistoryHandler(this.models.requestCounter),
+			Middlewares: []Middleware{timeout},
+		},
+		{
+			Name:    "PostRequestCountEvents",
+			Method:  POST,
+			Route:   "/requestcount/events",
+			Handler: requestcount.NewPostEventsHandler(this.models.requestCounter),
+		},
+		{
+			Name:    "PostWeighted",
+			Method:  POST,
+			Route:   "/requestcount/weighted",
+			Handler: requestcount.NewPostWeightedHandler(this.models.requestCounter),
+		},
+		{
+			Name:    "GetWeighted",
+			Method:  GET,
+			Route:   "/requestcount/weighted",
+			Handler: requestcount.NewGetWeightedHandler(this.models.requestCounter),
+		},
+		{
+			Name:    "PostValues",
+			Method:  POST,
+			Route:   "/requestcount/values",
+			Handler: requestcount.NewPostValuesHandler(this.models.requestCounter),
+		},
+		{
+			Name:    "GetValues",
+			Method:  GET,
+			Route:   "/requestcount/values",
+			Handler: requestcount.NewGetValuesHandler(this.models.requestCounter),
+		},
+		{
+			Name:    "GetLatency",
+			Method:  GET,
+			Route:   "/requestcount/latency",
+			Handler: requestcount.NewGetLatencyHandler(this.models.requestCounter),
+		},
+		{
+			Name:    "GetResponses",
+			Method:  GET,
+			Route:   "/requestcount/responses",
+			Handler: requestcount.NewGetResponsesHandler(this.models.requestCounter),
+		},
+		{
+			Name:    "PostSeries",
+			Method:  POST,
+			Route:   "/requestcount/series",
+			Handler: requestcount.NewPostSeriesHandler(this.models.requestCounter),
+		},
+		{
+			Name:    "GetSeriesQuery",
+			Method:  GET,
+			Route:   "/requestcount/query",
+			Handler: requestcount.NewGetSeriesQueryHandler(this.models.requestCounter),
+		},
+		{
+			Name:        "GetEval",
+			Method:      GET,
+			Route:       "/requestcount/eval",
+			Handler:     requestcount.NewGetEvalHandler(this.models.requestCounter),
+			Middlewares: []Middleware{timeout},
+		},
+		{
+			Name:    "GetAnomalies",
+			Method:  GET,
+			Route:   "/requestcount/anomalies",
+			Handler: requestcount.NewGetAnomaliesHandler(this.models.requestCounter),
+		},
+		{
+			Name:    "GetAlerts",
+			Method:  GET,
+			Route:   "/requestcount/alerts",
+			Handler: alerting.NewGetAlertsHandler(this.models.alerting),
+		},
+		{
+			Name:    "GetMetrics",
+			Method:  GET,
+			Route:   "/metrics",
+			Handler: metrics.NewGetMetricsHandler(this.metricsCollectors()...),
+		},
+		{
+			Name:        "GetLogLevel",
+			Method:      GET,
+			Route:       "/admin/loglevel",
+			Handler:     admin.NewGetLogLevelHandler(this.logLevels),
+			Middlewares: []Middleware{debugOnly},
+		},
+		{
+			Name:        "PutLogLevel",
+			Method:      PUT,
+			Route:       "/admin/loglevel",
+			Handler:     admin.NewPutLogLevelHandler(this.logLevels),
+			Middlewares: []Middleware{debugOnly},
+		},
+		{
+			Name:        "GetDebugLogs",
+			Method:      GET,
+			Route:       "/debug/logs",
+			Handler:     admin.NewGetLogsHandler(this.getLogRing()),
+			Middlewares: []Middleware{debugOnly},
+		},
 	}
 }
@@ -0,0 +1,79 @@
+package app
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/THE108/requestcounter/utils/errors"
+	"github.com/THE108/requestcounter/utils/log"
+	"github.com/THE108/requestcounter/utils/params"
+	"github.com/THE108/requestcounter/utils/stream"
+
+	"golang.org/x/net/context"
+)
+
+func (this *Application) createStreamRequestHandler(info *HandlerInfo, h IStreamHandler) http.Handler {
+	httpHandler := func(w http.ResponseWriter, req *http.Request) {
+		rw := newResponseWriter(w)
+		defer this.observeResponse(info, req, rw, time.Now())
+
+		ctx, cancel := context.WithCancel(this.createContext(info.Name, req))
+		defer finishServerSpan(ctx, rw)
+		defer rw.markPanic()
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			cancel()
+			this.writeResponse(ctx, rw, errors.New(http.StatusInternalServerError, "streaming is not supported"))
+			return
+		}
+
+		sse := stream.NewSSE(rw, flusher)
+
+		// the heartbeat goroutine must not write to the response after the handler returns
+		var wg sync.WaitGroup
+		defer func() {
+			cancel()
+			wg.Wait()
+		}()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			this.runStreamHeartbeat(ctx, cancel, req, sse)
+		}()
+
+		err := h.Stream(ctx, params.NewParams(req), sse)
+		if err == nil {
+			return
+		}
+
+		if !sse.Started() {
+			this.writeResponse(ctx, rw, err)
+			return
+		}
+
+		log.GetLoggerFromContext(ctx).Debugf("stream is finished: %s", err.Error())
+	}
+
+	return http.HandlerFunc(httpHandler)
+}
+
+// runStreamHeartbeat sends heartbeats until ctx is done,
+// it cancels ctx when the client disconnects or a heartbeat fails
+func (this *Application) runStreamHeartbeat(ctx context.Context, cancel func(), req *http.Request, sse *stream.SSE) {
+	defer cancel()
+	for {
+		select {
+		case <-time.After(this.config.StreamHeartbeat):
+			if err := sse.Heartbeat(); err != nil {
+				return
+			}
+		case <-req.Context().Done():
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
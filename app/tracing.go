@@ -0,0 +1,65 @@
+package app
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/THE108/requestcounter/utils/tracedata"
+	"github.com/THE108/requestcounter/utils/tracing"
+
+	"golang.org/x/net/context"
+)
+
+// initTracing creates tracer exporting spans to Zipkin collector, tracing is disabled if the collector is not set
+func (this *Application) initTracing() error {
+	if this.config.TracingCollectorURL == "" {
+		return nil
+	}
+
+	this.tracer = tracing.NewTracer(&tracing.Config{
+		ServiceName:   this.config.TracingServiceName,
+		CollectorURL:  this.config.TracingCollectorURL,
+		SampleRate:    *this.config.TracingSampleRate,
+		QueueSize:     this.config.TracingQueueSize,
+		BatchSize:     this.config.TracingBatchSize,
+		FlushInterval: this.config.TracingFlushInterval,
+		MaxAttempts:   this.config.TracingMaxAttempts,
+		Backoff:       this.config.TracingBackoff,
+		MaxBackoff:    this.config.TracingMaxBackoff,
+		Timeout:       this.config.TracingTimeout,
+		Logger:        this.newLogger("Tracing"),
+	})
+
+	this.closer.AddCloser(this.tracer)
+
+	return this.tracer.Run()
+}
+
+// startServerSpan starts span of the request named after the handler
+func (this *Application) startServerSpan(ctx context.Context, handlerName string, req *http.Request) context.Context {
+	span := this.tracer.StartServerSpan(handlerName, tracedata.GetTraceDataFromContext(ctx))
+	if span == nil {
+		return ctx
+	}
+
+	span.SetTag("http.method", req.Method)
+	span.SetTag("http.path", req.URL.Path)
+
+	return tracing.SetSpanToContext(ctx, span)
+}
+
+// finishServerSpan finishes span of the request with status of the response
+func finishServerSpan(ctx context.Context, rw *responseWriter) {
+	span := tracing.GetSpanFromContext(ctx)
+	if span == nil {
+		return
+	}
+
+	status := rw.Status()
+	span.SetTag("http.status_code", strconv.Itoa(status))
+	if status >= http.StatusInternalServerError && span.Tag("error") == "" {
+		span.SetTag("error", http.StatusText(status))
+	}
+
+	span.Finish()
+}
@@ -2,14 +2,19 @@ package app
 
 import (
 	"encoding/json"
+	"io"
 	"io/ioutil"
 	"net/http"
-	"os"
+	"strings"
+	"time"
 
 	"github.com/THE108/requestcounter/utils/errors"
+	"github.com/THE108/requestcounter/utils/etag"
 	"github.com/THE108/requestcounter/utils/log"
 	"github.com/THE108/requestcounter/utils/params"
+	"github.com/THE108/requestcounter/utils/stream"
 	"github.com/THE108/requestcounter/utils/tracedata"
+	"github.com/THE108/requestcounter/utils/tracing"
 
 	"github.com/gorilla/mux"
 	"golang.org/x/net/context"
@@ -37,6 +42,8 @@ const (
 
 const (
 	debugUrlParamName = "debug"
+
+	fallbackHandlerName = "Fallback"
 )
 
 // Info described routes
@@ -45,6 +52,8 @@ type HandlerInfo struct {
 	Method  string
 	Route   string
 	Handler interface{}
+	// Middlewares wrap the handler inside the global chain, the first one is the outermost
+	Middlewares []Middleware
 }
 
 // IGetHandler defines handlers that process GET-like requests
@@ -60,6 +69,26 @@ type IPostHandler interface {
 	GetBuffer() interface{}
 }
 
+// IStreamHandler defines handlers that push events to the client until ctx is done
+// (the client disconnected or the application is closing) or Stream returns.
+// Error returned before the first event is sent is written as a regular response.
+type IStreamHandler interface {
+	Stream(ctx context.Context, params params.Params, stream stream.IStream) error
+}
+
+// IETagGetter defines responses having entity tag,
+// such responses are not written if the tag matches If-None-Match header
+type IETagGetter interface {
+	GetETag() string
+}
+
+// IOutputGetter defines handlers returning output of the same type as GetOutput,
+// requests for formats that could not represent it are rejected with 406 before processing
+// as the processing could have side effects
+type IOutputGetter interface {
+	GetOutput() interface{}
+}
+
 type IHttpCodeGetter interface {
 	GetHttpCode() int
 }
@@ -77,27 +106,90 @@ func (this *Application) initRoutes() {
 
 		this.handlers[key] = info
 
+		// levels of handlers could be changed before the first request
+		this.logLevels.Level(info.Name)
+
 		this.addHandler(info)
 	}
+
+	this.addFallbackHandlers(handlersInfo)
+	this.logLevels.Level(fallbackHandlerName)
+
+	this.initMiddlewares()
+	this.handler = chain(this.router, this.middlewares...)
+}
+
+// addFallbackHandlers makes router respond to unknown routes with 404
+// and to known routes requested with unsupported method with 405 in the common error format
+func (this *Application) addFallbackHandlers(handlersInfo []*HandlerInfo) {
+	var routes []string
+	methods := make(map[string][]string)
+	for _, info := range handlersInfo {
+		if _, ok := methods[info.Route]; !ok {
+			routes = append(routes, info.Route)
+		}
+		methods[info.Route] = append(methods[info.Route], info.Method)
+	}
+
+	// routes are matched in order of adding so these match only if no method matched
+	for _, route := range routes {
+		allowed := strings.Join(methods[route], ", ")
+		this.router.Handle(route, http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set("Allow", allowed)
+			this.writeFallbackResponse(rw, req, errors.Newf(http.StatusMethodNotAllowed, "method %s is not allowed, allowed: %s", req.Method, allowed))
+		}))
+	}
+
+	this.router.NotFoundHandler = http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		this.writeFallbackResponse(rw, req, errors.New(http.StatusNotFound, "route "+req.URL.Path+" is not found"))
+	})
+}
+
+func (this *Application) writeFallbackResponse(w http.ResponseWriter, req *http.Request, err error) {
+	rw := newResponseWriter(w)
+	ctx := this.createContext(fallbackHandlerName, req)
+	defer finishServerSpan(ctx, rw)
+
+	// format of error response is negotiated if possible, json is used otherwise
+	if negotiated, negotiateErr := this.negotiate(ctx, req); negotiateErr == nil {
+		ctx = negotiated
+	}
+	this.writeResponse(ctx, rw, err)
 }
 
 func (this *Application) addHandler(info *HandlerInfo) {
 	var httpHandler http.Handler
 	switch h := info.Handler.(type) {
 	case IGetHandler:
-		httpHandler = this.createGetRequestHandler(info.Name, h)
+		httpHandler = this.createGetRequestHandler(info, h)
 	case IPostHandler:
-		httpHandler = this.createPostRequestHandler(info.Name, h)
+		httpHandler = this.createPostRequestHandler(info, h)
+	case IStreamHandler:
+		httpHandler = this.createStreamRequestHandler(info, h)
 	default:
 		panic("unknown type")
 	}
 
-	this.router.Handle(info.Route, httpHandler).Methods(info.Method)
+	this.router.Handle(info.Route, chain(httpHandler, info.Middlewares...)).Methods(info.Method)
 }
 
-func (this *Application) createGetRequestHandler(handlerName string, h IGetHandler) http.Handler {
-	httpHandler := func(rw http.ResponseWriter, req *http.Request) {
-		ctx := this.createContext(handlerName, req)
+func (this *Application) createGetRequestHandler(info *HandlerInfo, h IGetHandler) http.Handler {
+	httpHandler := func(w http.ResponseWriter, req *http.Request) {
+		rw := newResponseWriter(w)
+		defer this.observeResponse(info, req, rw, time.Now())
+
+		ctx, err := this.negotiate(this.createContext(info.Name, req), req)
+		defer finishServerSpan(ctx, rw)
+		defer rw.markPanic()
+
+		if err == nil {
+			err = checkOutput(ctx, h)
+		}
+		if err != nil {
+			this.writeResponse(ctx, rw, err)
+			return
+		}
+
 		params := params.NewParams(req)
 
 		output, err := h.Process(ctx, params)
@@ -106,6 +198,10 @@ func (this *Application) createGetRequestHandler(handlerName string, h IGetHandl
 			return
 		}
 
+		if this.writeNotModified(req, rw, output) {
+			return
+		}
+
 		if req.Method == HEAD {
 			// since HEAD request SHOULD NOT return a message-body in the response,
 			// so set data empty before request finish
@@ -119,9 +215,23 @@ func (this *Application) createGetRequestHandler(handlerName string, h IGetHandl
 	return http.HandlerFunc(httpHandler)
 }
 
-func (this *Application) createPostRequestHandler(handlerName string, h IPostHandler) http.Handler {
-	httpHandler := func(rw http.ResponseWriter, req *http.Request) {
-		ctx := this.createContext(handlerName, req)
+func (this *Application) createPostRequestHandler(info *HandlerInfo, h IPostHandler) http.Handler {
+	httpHandler := func(w http.ResponseWriter, req *http.Request) {
+		rw := newResponseWriter(w)
+		defer this.observeResponse(info, req, rw, time.Now())
+
+		ctx, err := this.negotiate(this.createContext(info.Name, req), req)
+		defer finishServerSpan(ctx, rw)
+		defer rw.markPanic()
+
+		if err == nil {
+			err = checkOutput(ctx, h)
+		}
+		if err != nil {
+			this.writeResponse(ctx, rw, err)
+			return
+		}
+
 		params := params.NewParams(req)
 
 		var input interface{}
@@ -144,44 +254,102 @@ func (this *Application) createPostRequestHandler(handlerName string, h IPostHan
 	return http.HandlerFunc(httpHandler)
 }
 
+func (this *Application) observeResponse(info *HandlerInfo, req *http.Request, rw *responseWriter, start time.Time) {
+	this.models.requestCounter.ObserveLatency(info.Name, time.Since(start))
+	this.models.requestCounter.CountResponse(info.Route, req.Method, rw.Status())
+}
+
+// writeNotModified sets ETag header of the output and writes status 304 if it matches If-None-Match header
+func (this *Application) writeNotModified(req *http.Request, rw http.ResponseWriter, output interface{}) bool {
+	getter, ok := output.(IETagGetter)
+	if !ok {
+		return false
+	}
+
+	tag := getter.GetETag()
+	if tag == "" {
+		return false
+	}
+
+	rw.Header().Set("ETag", tag)
+
+	if !etag.Matches(req.Header.Get("If-None-Match"), tag) {
+		return false
+	}
+
+	rw.WriteHeader(http.StatusNotModified)
+	return true
+}
+
 func (this *Application) getInputFromRequest(req *http.Request, input interface{}) error {
 	requestBody, err := ioutil.ReadAll(req.Body)
+	if err == errBodyTooLarge {
+		return err
+	}
 	if err != nil {
 		return errors.Wrap(err, http.StatusInternalServerError)
 	}
 
 	if err := json.Unmarshal(requestBody, input); err != nil {
-		return errors.Wrap(err, http.StatusInternalServerError)
+		return errors.Wrap(err, http.StatusBadRequest).WithReason("invalid_body")
 	}
 
 	return nil
 }
 
-func (this *Application) makeTraceDataLoggerPrefix(data *tracedata.TraceData) string {
-	return data.TraceID + "|" + data.SpanID + "|" + data.ParentSpanID
+// getLogLevelByHandlerName returns level of the handler or DEBUG level for the allowed debug request
+func (this *Application) getLogLevelByHandlerName(handlerName string, req *http.Request) *log.Level {
+	if _, debug := getDebugRequest(req.Context()); debug {
+		return log.NewLevel(log.DEBUG)
+	}
+	return this.logLevels.Level(handlerName)
 }
 
-func (this *Application) getLogLevelByHandlerName(handlerName string, req *http.Request) int {
-	if _, debug := req.URL.Query()[debugUrlParamName]; debug {
-		return log.DEBUG
+// getLogOutput returns log output and the capture of the request if its logs are returned inline
+func (this *Application) getLogOutput(req *http.Request) io.Writer {
+	if debug, ok := getDebugRequest(req.Context()); ok && debug.capture != nil {
+		return log.NewTee(this.logOutput, debug.capture)
 	}
-	return this.config.LogLevel
+	return this.logOutput
 }
 
 func (this *Application) createContext(handlerName string, req *http.Request) context.Context {
-	traceData := tracedata.GetTraceDataFromRequest(req)
-	ctx := tracedata.SetTraceDataToContext(context.Background(), traceData)
-	prefix := this.makeTraceDataLoggerPrefix(traceData)
+	// trace data is set by TraceData middleware, it is read from the request if the middleware is skipped
+	ctx := req.Context()
+	traceData := tracedata.GetTraceDataFromContext(ctx)
+	if traceData.TraceID == "" {
+		traceData = this.tracePropagator.GetTraceDataFromRequest(req)
+		ctx = tracedata.SetTraceDataToContext(ctx, traceData)
+	}
+
+	ctx = this.startServerSpan(ctx, handlerName, req)
+
 	level := this.getLogLevelByHandlerName(handlerName, req)
-	logger := log.New(os.Stderr, prefix, level)
+	logger := log.NewWithLevel(this.getLogOutput(req), handlerName, level, this.logEncoder)
+	ctx = log.SetLoggerToContext(ctx, logger)
 
-	logger.Debug(req.Method + " " + req.RequestURI)
+	log.GetLoggerFromContext(ctx).Debug(req.Method + " " + req.RequestURI)
 
-	return log.SetLoggerToContext(ctx, logger)
+	return ctx
+}
+
+// codedError maps err to CodedError, internal errors are logged
+func (this *Application) codedError(ctx context.Context, err error) *errors.CodedError {
+	codedErr := errors.From(err)
+	if codedErr.GetHttpCode() >= http.StatusInternalServerError {
+		log.GetLoggerFromContext(ctx).Error(err.Error())
+		tracing.GetSpanFromContext(ctx).SetTag("error", err.Error())
+	}
+	return codedErr
 }
 
 // SetResult prepares API response for success scenario
 func (this *Application) writeResponse(ctx context.Context, rw http.ResponseWriter, data interface{}) {
+	// plain errors are mapped to status codes, unknown ones to 500
+	if err, ok := data.(error); ok {
+		data = this.codedError(ctx, err)
+	}
+
 	httpCode := http.StatusOK
 	if r, ok := data.(IHttpCodeGetter); ok {
 		httpCode = r.GetHttpCode()
@@ -192,18 +360,20 @@ func (this *Application) writeResponse(ctx context.Context, rw http.ResponseWrit
 		return
 	}
 
-	var err error
+	// sequence of bytes is written as is
 	response, ok := data.([]byte)
+	contentType := "text/plain; charset=utf-8"
 	if !ok {
-		// If response is not a sequence of bytes, we need to serialize it
-		response, err = json.Marshal(data)
-	}
-
-	if err != nil {
-		log.GetLoggerFromContext(ctx).Error(err.Error())
-		httpCode = http.StatusInternalServerError
+		var err error
+		response, contentType, err = getResponseFormat(ctx).encode(data)
+		if err != nil {
+			codedErr := this.codedError(ctx, err)
+			httpCode = codedErr.GetHttpCode()
+			response, contentType, _ = defaultResponseFormat.encode(codedErr)
+		}
 	}
 
+	rw.Header().Set("Content-Type", contentType)
 	rw.WriteHeader(httpCode)
 	if _, err := rw.Write(response); err != nil {
 		log.GetLoggerFromContext(ctx).Error(err.Error())
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/THE108/requestcounter/utils/log"
+	"github.com/THE108/requestcounter/utils/tracedata"
 
 	"gopkg.in/yaml.v2"
 )
@@ -16,18 +17,160 @@ const (
 	defaultIntervalDuration = 600 * time.Millisecond
 	defaultFilename         = "/tmp/requestcounter.dat"
 	defaultPersistDuration  = 5 * time.Second
+	defaultRequestTimeout   = 30 * time.Second
+	defaultMaxBodySize      = 1 << 20
+	defaultCORSMaxAge       = 10 * time.Minute
+	defaultLogQueueSize     = 1024
+	defaultLogPolicy        = "block"
+	defaultLogFileMaxSize   = 100 << 20
+	defaultLogRingSize      = 1000
+	defaultLogRingEntrySize = 4096
+	defaultTracingService   = "requestcounter"
+	defaultTracingQueueSize = 1000
+	defaultTracingBatchSize = 100
+	defaultTracingInterval  = time.Second
+	defaultTracingAttempts  = 3
+	defaultTracingBackoff   = 500 * time.Millisecond
+	defaultTracingMaxDelay  = 30 * time.Second
+	defaultTracingTimeout   = 5 * time.Second
+	defaultSyslogNetwork    = "unixgram"
+	defaultSyslogAddress    = "/dev/log"
+	defaultSyslogFacility   = "daemon"
+	defaultSyslogTag        = "requestcounter"
+	defaultLogFileBackups   = 5
+	defaultAccessLogFormat  = "combined"
+	defaultAccessLogMaxSize = 100 << 20
+	defaultAccessLogBackups = 5
+	defaultFutureTolerance  = time.Second
+	defaultWeightedMaxKeys  = 1000
+	defaultSeriesMaxCount   = 10000
+	defaultStreamHeartbeat  = 15 * time.Second
+	defaultStreamMaxSubs    = 100
+	defaultAlertMaxAttempts = 5
+	defaultAlertBackoff     = 500 * time.Millisecond
+	defaultAlertMaxBackoff  = 30 * time.Second
+	defaultAlertTimeout     = 5 * time.Second
+	defaultAnomalyMethod    = "zscore"
+	defaultAnomalyThreshold = 3
+	defaultAnomalyMaxEvents = 100
+	defaultArchiveDir       = "/tmp/requestcounter-archive"
+	defaultArchiveSegSize   = 1 << 20
+	defaultArchiveSegAge    = time.Hour
 )
 
+type AlertRule struct {
+	Name       string        `yaml:"name"`
+	Counter    string        `yaml:"counter"`
+	Window     time.Duration `yaml:"window"`
+	Comparator string        `yaml:"comparator"`
+	Threshold  float64       `yaml:"threshold"`
+	For        time.Duration `yaml:"for"`
+	Webhook    string        `yaml:"webhook"`
+}
+
 type Config struct {
 	Host             string        `yaml:"host"`
 	Port             int           `yaml:"port"`
 	LogLevelString   string        `yaml:"log-level"`
 	LogLevel         int           `yaml:"-"`
+	LogFormat        string        `yaml:"log-format"`
+	LogTraceFields   bool          `yaml:"log-trace-fields"`
 	IntervalCount    int           `yaml:"interval-count"`
 	IntervalDuration time.Duration `yaml:"interval-duration"`
 	Persistent       bool          `yaml:"persistent"`
 	Filename         string        `yaml:"filename"`
 	PersistDuration  time.Duration `yaml:"persist-duration"`
+
+	// LogLevelStrings are levels of handlers and components by name, others use log-level
+	LogLevelStrings map[string]string `yaml:"log-levels"`
+	LogLevels       map[string]int    `yaml:"-"`
+
+	LogAsync          bool   `yaml:"log-async"`
+	LogQueueSize      int    `yaml:"log-queue-size"`
+	LogOverflowPolicy string `yaml:"log-overflow-policy"`
+
+	// LogOutput is stderr, file, syslog or journald
+	LogOutput         string        `yaml:"log-output"`
+	LogFile           string        `yaml:"log-file"`
+	LogFileMaxSize    int64         `yaml:"log-file-max-size"`
+	LogFileMaxAge     time.Duration `yaml:"log-file-max-age"`
+	LogFileMaxBackups int           `yaml:"log-file-max-backups"`
+	LogFileCompress   bool          `yaml:"log-file-compress"`
+	LogSyslogNetwork  string        `yaml:"log-syslog-network"`
+	LogSyslogAddress  string        `yaml:"log-syslog-address"`
+	LogSyslogFacility string        `yaml:"log-syslog-facility"`
+	LogSyslogTag      string        `yaml:"log-syslog-tag"`
+	LogJournalSocket  string        `yaml:"log-journal-socket"`
+
+	// LogRingSize is a count of recent log entries kept in memory for /debug/logs, negative disables it
+	LogRingSize      int `yaml:"log-ring-size"`
+	LogRingEntrySize int `yaml:"log-ring-entry-size"`
+
+	// debug param and admin endpoints are allowed with X-Debug-Secret header or from trusted networks
+	DebugSecret          string   `yaml:"debug-secret"`
+	DebugTrustedNetworks []string `yaml:"debug-trusted-networks"`
+
+	// TracePropagation are formats of trace headers read by precedence and written to outgoing requests
+	TracePropagation    []string `yaml:"trace-propagation"`
+	TraceResponseHeader string   `yaml:"trace-response-header"`
+
+	// spans are exported to Zipkin collector if TracingCollectorURL is set;
+	// TracingSampleRate is a pointer so explicit 0 (record only traces sampled by the caller) differs from unset
+	TracingCollectorURL  string        `yaml:"tracing-collector-url"`
+	TracingServiceName   string        `yaml:"tracing-service-name"`
+	TracingSampleRate    *float64      `yaml:"tracing-sample-rate"`
+	TracingQueueSize     int           `yaml:"tracing-queue-size"`
+	TracingBatchSize     int           `yaml:"tracing-batch-size"`
+	TracingFlushInterval time.Duration `yaml:"tracing-flush-interval"`
+	TracingMaxAttempts   int           `yaml:"tracing-max-attempts"`
+	TracingBackoff       time.Duration `yaml:"tracing-backoff"`
+	TracingMaxBackoff    time.Duration `yaml:"tracing-max-backoff"`
+	TracingTimeout       time.Duration `yaml:"tracing-timeout"`
+
+	RequestTimeout     time.Duration `yaml:"request-timeout"`
+	MaxBodySize        int64         `yaml:"max-body-size"`
+	Compression        bool          `yaml:"compression"`
+	CORSAllowedOrigins []string      `yaml:"cors-allowed-origins"`
+	CORSAllowedMethods []string      `yaml:"cors-allowed-methods"`
+	CORSAllowedHeaders []string      `yaml:"cors-allowed-headers"`
+	CORSMaxAge         time.Duration `yaml:"cors-max-age"`
+
+	// AccessLogSampleRate is a pointer so explicit 0 (log only 5xx responses) differs from unset
+	AccessLog           bool          `yaml:"access-log"`
+	AccessLogFormat     string        `yaml:"access-log-format"`
+	AccessLogFile       string        `yaml:"access-log-file"`
+	AccessLogMaxSize    int64         `yaml:"access-log-max-size"`
+	AccessLogMaxBackups int           `yaml:"access-log-max-backups"`
+	AccessLogMaxAge     time.Duration `yaml:"access-log-max-age"`
+	AccessLogCompress   bool          `yaml:"access-log-compress"`
+	AccessLogSampleRate *float64      `yaml:"access-log-sample-rate"`
+
+	EventsFutureTolerance time.Duration `yaml:"events-future-tolerance"`
+	WeightedMaxKeys       int           `yaml:"weighted-max-keys"`
+	ValueCounters         []string      `yaml:"value-counters"`
+	SeriesMaxCount        int           `yaml:"series-max-count"`
+
+	StreamHeartbeat      time.Duration `yaml:"stream-heartbeat"`
+	StreamMaxSubscribers int           `yaml:"stream-max-subscribers"`
+
+	AlertRules       []AlertRule   `yaml:"alert-rules"`
+	AlertMaxAttempts int           `yaml:"alert-max-attempts"`
+	AlertBackoff     time.Duration `yaml:"alert-backoff"`
+	AlertMaxBackoff  time.Duration `yaml:"alert-max-backoff"`
+	AlertTimeout     time.Duration `yaml:"alert-timeout"`
+
+	AnomalyDetection bool    `yaml:"anomaly-detection"`
+	AnomalyMethod    string  `yaml:"anomaly-method"`
+	AnomalyWindow    int     `yaml:"anomaly-window"`
+	AnomalyThreshold float64 `yaml:"anomaly-threshold"`
+	AnomalyMaxEvents int     `yaml:"anomaly-max-events"`
+
+	Archive               bool          `yaml:"archive"`
+	ArchiveDir            string        `yaml:"archive-dir"`
+	ArchiveSegmentSize    int64         `yaml:"archive-segment-size"`
+	ArchiveSegmentAge     time.Duration `yaml:"archive-segment-age"`
+	ArchiveRetentionCount int           `yaml:"archive-retention-count"`
+	ArchiveRetentionAge   time.Duration `yaml:"archive-retention-age"`
 }
 
 func LoadConfigFromFile() (*Config, error) {
@@ -66,17 +209,111 @@ func (cfg *Config) setDefaults() {
 		cfg.Port = defaultListenPort
 	}
 
-	switch cfg.LogLevelString {
-	case "DEBUG", "debug":
-		cfg.LogLevel = log.DEBUG
-	case "INFO", "info":
-		cfg.LogLevel = log.INFO
-	case "WARNING", "warning":
-		cfg.LogLevel = log.WARNING
-	case "ERROR", "error":
-		cfg.LogLevel = log.ERROR
-	default:
-		cfg.LogLevel = log.INFO
+	cfg.LogLevel = parseLogLevel(cfg.LogLevelString)
+
+	cfg.LogLevels = make(map[string]int, len(cfg.LogLevelStrings))
+	for name, level := range cfg.LogLevelStrings {
+		cfg.LogLevels[name] = parseLogLevel(level)
+	}
+
+	if cfg.LogFormat != log.JSONFormat {
+		cfg.LogFormat = log.TextFormat
+	}
+
+	if cfg.LogQueueSize == 0 {
+		cfg.LogQueueSize = defaultLogQueueSize
+	}
+
+	if cfg.LogOverflowPolicy == "" {
+		cfg.LogOverflowPolicy = defaultLogPolicy
+	}
+
+	if cfg.LogOutput == "" {
+		cfg.LogOutput = "stderr"
+		if cfg.LogFile != "" {
+			cfg.LogOutput = "file"
+		}
+	}
+
+	if cfg.LogFileMaxSize == 0 {
+		cfg.LogFileMaxSize = defaultLogFileMaxSize
+	}
+
+	if cfg.LogFileMaxBackups == 0 {
+		cfg.LogFileMaxBackups = defaultLogFileBackups
+	}
+
+	if cfg.LogRingSize == 0 {
+		cfg.LogRingSize = defaultLogRingSize
+	}
+
+	if cfg.LogRingEntrySize == 0 {
+		cfg.LogRingEntrySize = defaultLogRingEntrySize
+	}
+
+	if cfg.LogSyslogNetwork == "" {
+		cfg.LogSyslogNetwork = defaultSyslogNetwork
+	}
+
+	if cfg.LogSyslogAddress == "" {
+		cfg.LogSyslogAddress = defaultSyslogAddress
+	}
+
+	if cfg.LogSyslogFacility == "" {
+		cfg.LogSyslogFacility = defaultSyslogFacility
+	}
+
+	if cfg.LogSyslogTag == "" {
+		cfg.LogSyslogTag = defaultSyslogTag
+	}
+
+	if cfg.LogJournalSocket == "" {
+		cfg.LogJournalSocket = log.DefaultJournalSocket
+	}
+
+	if len(cfg.TracePropagation) == 0 {
+		cfg.TracePropagation = tracedata.DefaultFormats
+	}
+
+	if cfg.TraceResponseHeader == "" {
+		cfg.TraceResponseHeader = tracedata.TraceIDHeader
+	}
+
+	if cfg.TracingServiceName == "" {
+		cfg.TracingServiceName = defaultTracingService
+	}
+
+	if cfg.TracingSampleRate == nil {
+		rate := 1.0
+		cfg.TracingSampleRate = &rate
+	}
+
+	if cfg.TracingQueueSize == 0 {
+		cfg.TracingQueueSize = defaultTracingQueueSize
+	}
+
+	if cfg.TracingBatchSize == 0 {
+		cfg.TracingBatchSize = defaultTracingBatchSize
+	}
+
+	if cfg.TracingFlushInterval == 0 {
+		cfg.TracingFlushInterval = defaultTracingInterval
+	}
+
+	if cfg.TracingMaxAttempts == 0 {
+		cfg.TracingMaxAttempts = defaultTracingAttempts
+	}
+
+	if cfg.TracingBackoff == 0 {
+		cfg.TracingBackoff = defaultTracingBackoff
+	}
+
+	if cfg.TracingMaxBackoff == 0 {
+		cfg.TracingMaxBackoff = defaultTracingMaxDelay
+	}
+
+	if cfg.TracingTimeout == 0 {
+		cfg.TracingTimeout = defaultTracingTimeout
 	}
 
 	if cfg.IntervalCount == 0 {
@@ -94,4 +331,114 @@ func (cfg *Config) setDefaults() {
 	if cfg.PersistDuration == 0 {
 		cfg.PersistDuration = defaultPersistDuration
 	}
+
+	if cfg.RequestTimeout == 0 {
+		cfg.RequestTimeout = defaultRequestTimeout
+	}
+
+	if cfg.MaxBodySize == 0 {
+		cfg.MaxBodySize = defaultMaxBodySize
+	}
+
+	if len(cfg.CORSAllowedMethods) == 0 {
+		cfg.CORSAllowedMethods = []string{"GET", "POST"}
+	}
+
+	if len(cfg.CORSAllowedHeaders) == 0 {
+		cfg.CORSAllowedHeaders = []string{"Accept", "Content-Type", "If-None-Match"}
+	}
+
+	if cfg.CORSMaxAge == 0 {
+		cfg.CORSMaxAge = defaultCORSMaxAge
+	}
+
+	if cfg.AccessLogFormat == "" {
+		cfg.AccessLogFormat = defaultAccessLogFormat
+	}
+
+	if cfg.AccessLogMaxSize == 0 {
+		cfg.AccessLogMaxSize = defaultAccessLogMaxSize
+	}
+
+	if cfg.AccessLogMaxBackups == 0 {
+		cfg.AccessLogMaxBackups = defaultAccessLogBackups
+	}
+
+	if cfg.AccessLogSampleRate == nil {
+		rate := 1.0
+		cfg.AccessLogSampleRate = &rate
+	}
+
+	if cfg.EventsFutureTolerance == 0 {
+		cfg.EventsFutureTolerance = defaultFutureTolerance
+	}
+
+	if cfg.WeightedMaxKeys == 0 {
+		cfg.WeightedMaxKeys = defaultWeightedMaxKeys
+	}
+
+	if cfg.SeriesMaxCount == 0 {
+		cfg.SeriesMaxCount = defaultSeriesMaxCount
+	}
+
+	if cfg.StreamHeartbeat == 0 {
+		cfg.StreamHeartbeat = defaultStreamHeartbeat
+	}
+
+	if cfg.StreamMaxSubscribers == 0 {
+		cfg.StreamMaxSubscribers = defaultStreamMaxSubs
+	}
+
+	if cfg.AlertMaxAttempts == 0 {
+		cfg.AlertMaxAttempts = defaultAlertMaxAttempts
+	}
+
+	if cfg.AlertBackoff == 0 {
+		cfg.AlertBackoff = defaultAlertBackoff
+	}
+
+	if cfg.AlertMaxBackoff == 0 {
+		cfg.AlertMaxBackoff = defaultAlertMaxBackoff
+	}
+
+	if cfg.AlertTimeout == 0 {
+		cfg.AlertTimeout = defaultAlertTimeout
+	}
+
+	if cfg.AnomalyMethod == "" {
+		cfg.AnomalyMethod = defaultAnomalyMethod
+	}
+
+	if cfg.AnomalyWindow == 0 {
+		cfg.AnomalyWindow = cfg.IntervalCount
+	}
+
+	if cfg.AnomalyThreshold == 0 {
+		cfg.AnomalyThreshold = defaultAnomalyThreshold
+	}
+
+	if cfg.AnomalyMaxEvents == 0 {
+		cfg.AnomalyMaxEvents = defaultAnomalyMaxEvents
+	}
+
+	if cfg.ArchiveDir == "" {
+		cfg.ArchiveDir = defaultArchiveDir
+	}
+
+	if cfg.ArchiveSegmentSize == 0 {
+		cfg.ArchiveSegmentSize = defaultArchiveSegSize
+	}
+
+	if cfg.ArchiveSegmentAge == 0 {
+		cfg.ArchiveSegmentAge = defaultArchiveSegAge
+	}
+}
+
+// parseLogLevel returns level by name, unknown names are INFO
+func parseLogLevel(name string) int {
+	level, err := log.ParseLevel(name)
+	if err != nil {
+		return log.INFO
+	}
+	return level
 }
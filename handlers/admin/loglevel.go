@@ -0,0 +1,85 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/THE108/requestcounter/utils/errors"
+	"github.com/THE108/requestcounter/utils/log"
+	"github.com/THE108/requestcounter/utils/params"
+
+	"golang.org/x/net/context"
+)
+
+type ILogLevels interface {
+	Lookup(name string) (*log.Level, bool)
+	All() []log.NamedLevel
+}
+
+type LogLevels struct {
+	Levels []log.NamedLevel `json:"levels"`
+}
+
+// LogLevelChange sets level of handler or component name, empty name changes the root level.
+// Empty level makes named level inherit the root level.
+type LogLevelChange struct {
+	Name  string `json:"name"`
+	Level string `json:"level"`
+}
+
+type GetLogLevelHandler struct {
+	levels ILogLevels
+}
+
+func NewGetLogLevelHandler(levels ILogLevels) *GetLogLevelHandler {
+	return &GetLogLevelHandler{
+		levels: levels,
+	}
+}
+
+func (handler *GetLogLevelHandler) Process(ctx context.Context, _ params.Params) (interface{}, error) {
+	return &LogLevels{Levels: handler.levels.All()}, nil
+}
+
+type PutLogLevelHandler struct {
+	levels ILogLevels
+}
+
+func NewPutLogLevelHandler(levels ILogLevels) *PutLogLevelHandler {
+	return &PutLogLevelHandler{
+		levels: levels,
+	}
+}
+
+func (handler *PutLogLevelHandler) GetBuffer() interface{} {
+	return &LogLevelChange{}
+}
+
+func (handler *PutLogLevelHandler) GetOutput() interface{} {
+	return &LogLevels{}
+}
+
+func (handler *PutLogLevelHandler) Process(ctx context.Context, data interface{}, _ params.Params) (interface{}, error) {
+	change := data.(*LogLevelChange)
+
+	level, ok := handler.levels.Lookup(change.Name)
+	if !ok {
+		return nil, errors.New(http.StatusNotFound, "unknown logger "+change.Name).WithReason("unknown_logger")
+	}
+
+	if change.Level == "" {
+		if change.Name == "" {
+			return nil, errors.New(http.StatusBadRequest, "root level could not be reset").WithReason("invalid_level")
+		}
+		level.Reset()
+	} else {
+		value, err := log.ParseLevel(change.Level)
+		if err != nil {
+			return nil, errors.Wrap(err, http.StatusBadRequest).WithReason("invalid_level")
+		}
+		level.Set(value)
+	}
+
+	log.GetLoggerFromContext(ctx).Infof("log level of %q is changed to %q", change.Name, change.Level)
+
+	return &LogLevels{Levels: handler.levels.All()}, nil
+}
@@ -0,0 +1,94 @@
+package admin
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"github.com/THE108/requestcounter/utils/errors"
+	"github.com/THE108/requestcounter/utils/log"
+	"github.com/THE108/requestcounter/utils/params"
+
+	"golang.org/x/net/context"
+)
+
+const defaultLogsLimit = 100
+
+var errLogRingDisabled = errors.New(http.StatusNotFound, "log ring is disabled").WithReason("log_ring_disabled")
+
+type ILogRing interface {
+	Entries(filter *log.RingFilter) []log.RingEntry
+}
+
+type Logs struct {
+	Entries []log.RingEntry `json:"entries"`
+}
+
+// PlainText returns log lines as these are written to the output
+func (logs *Logs) PlainText() string {
+	var buf bytes.Buffer
+	for _, entry := range logs.Entries {
+		buf.WriteString(entry.Line)
+		if !strings.HasSuffix(entry.Line, "\n") {
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.String()
+}
+
+type GetLogsHandler struct {
+	ring ILogRing
+}
+
+// NewGetLogsHandler returns handler of recent logs, ring is nil if it is disabled
+func NewGetLogsHandler(ring ILogRing) *GetLogsHandler {
+	return &GetLogsHandler{
+		ring: ring,
+	}
+}
+
+func (handler *GetLogsHandler) Process(ctx context.Context, params params.Params) (interface{}, error) {
+	if handler.ring == nil {
+		return nil, errLogRingDisabled
+	}
+
+	levelName, err := params.String("level", false, "debug")
+	if err != nil {
+		return nil, errors.Wrap(err, http.StatusBadRequest)
+	}
+
+	level, err := log.ParseLevel(levelName)
+	if err != nil {
+		return nil, errors.Wrap(err, http.StatusBadRequest).WithReason("invalid_level")
+	}
+
+	traceID, err := params.String("trace_id", false)
+	if err != nil {
+		return nil, errors.Wrap(err, http.StatusBadRequest)
+	}
+
+	from, err := params.Time("from", false)
+	if err != nil {
+		return nil, errors.Wrap(err, http.StatusBadRequest)
+	}
+
+	to, err := params.Time("to", false)
+	if err != nil {
+		return nil, errors.Wrap(err, http.StatusBadRequest)
+	}
+
+	limit, err := params.Uint64("limit", false, defaultLogsLimit)
+	if err != nil {
+		return nil, errors.Wrap(err, http.StatusBadRequest)
+	}
+
+	entries := handler.ring.Entries(&log.RingFilter{
+		Level:   level,
+		TraceID: traceID,
+		From:    from,
+		To:      to,
+		Limit:   int(limit),
+	})
+
+	return &Logs{Entries: entries}, nil
+}
@@ -0,0 +1,26 @@
+package alerting
+
+import (
+	"github.com/THE108/requestcounter/models/alerting"
+	"github.com/THE108/requestcounter/utils/params"
+
+	"golang.org/x/net/context"
+)
+
+type IAlertsGetter interface {
+	Alerts() []alerting.Alert
+}
+
+type GetAlertsHandler struct {
+	model IAlertsGetter
+}
+
+func NewGetAlertsHandler(model IAlertsGetter) *GetAlertsHandler {
+	return &GetAlertsHandler{
+		model: model,
+	}
+}
+
+func (handler *GetAlertsHandler) Process(ctx context.Context, _ params.Params) (interface{}, error) {
+	return handler.model.Alerts(), nil
+}
@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"github.com/THE108/requestcounter/utils/metrics"
+	"github.com/THE108/requestcounter/utils/params"
+
+	"golang.org/x/net/context"
+)
+
+type GetMetricsHandler struct {
+	collectors []metrics.ICollector
+}
+
+func NewGetMetricsHandler(collectors ...metrics.ICollector) *GetMetricsHandler {
+	return &GetMetricsHandler{
+		collectors: collectors,
+	}
+}
+
+func (handler *GetMetricsHandler) Process(ctx context.Context, _ params.Params) (interface{}, error) {
+	w := metrics.NewWriter()
+	for _, collector := range handler.collectors {
+		collector.WriteMetrics(w)
+	}
+
+	return w.Bytes(), nil
+}
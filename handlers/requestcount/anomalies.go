@@ -0,0 +1,31 @@
+package requestcount
+
+import (
+	"github.com/THE108/requestcounter/models/requestcount"
+	"github.com/THE108/requestcounter/utils/params"
+
+	"golang.org/x/net/context"
+)
+
+type IAnomaliesGetter interface {
+	Anomalies(ctx context.Context) (*requestcount.Anomalies, error)
+}
+
+type GetAnomaliesHandler struct {
+	model IAnomaliesGetter
+}
+
+func NewGetAnomaliesHandler(model IAnomaliesGetter) *GetAnomaliesHandler {
+	return &GetAnomaliesHandler{
+		model: model,
+	}
+}
+
+func (handler *GetAnomaliesHandler) Process(ctx context.Context, _ params.Params) (interface{}, error) {
+	anomalies, err := handler.model.Anomalies(ctx)
+	if err != nil {
+		return nil, wrapModelError(err)
+	}
+
+	return anomalies, nil
+}
@@ -0,0 +1,60 @@
+package requestcount
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/THE108/requestcounter/models/requestcount"
+	"github.com/THE108/requestcounter/utils/errors"
+	"github.com/THE108/requestcounter/utils/etag"
+	"github.com/THE108/requestcounter/utils/params"
+
+	"golang.org/x/net/context"
+)
+
+const maxWait = time.Minute
+
+type IRequestCountWaiter interface {
+	Peek() *requestcount.RequestCount
+	Wait(ctx context.Context, etag string, timeout time.Duration) *requestcount.RequestCount
+}
+
+type GetCurrentHandler struct {
+	model IRequestCountWaiter
+}
+
+func NewGetCurrentHandler(model IRequestCountWaiter) *GetCurrentHandler {
+	return &GetCurrentHandler{
+		model: model,
+	}
+}
+
+// Process returns the window count without counting the request itself.
+// With wait it blocks until the ring state differs from If-None-Match (or the state at the moment of request).
+func (handler *GetCurrentHandler) Process(ctx context.Context, params params.Params) (interface{}, error) {
+	wait, err := params.Duration("wait", false, 0)
+	if err != nil {
+		return nil, errors.Wrap(err, http.StatusBadRequest)
+	}
+
+	if wait < 0 || wait > maxWait {
+		return nil, errors.New(http.StatusBadRequest, "wait must be between 0 and "+maxWait.String())
+	}
+
+	var count *requestcount.RequestCount
+	if wait == 0 {
+		count = handler.model.Peek()
+	} else {
+		var current string
+		if tags := etag.Tags(params.Request.Header.Get("If-None-Match")); len(tags) > 0 {
+			current = tags[0]
+		}
+		count = handler.model.Wait(ctx, current, wait)
+	}
+
+	if count == nil {
+		return nil, wrapModelError(requestcount.ErrClosed)
+	}
+
+	return count, nil
+}
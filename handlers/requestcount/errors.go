@@ -0,0 +1,41 @@
+package requestcount
+
+import (
+	"net/http"
+
+	"github.com/THE108/requestcounter/models/requestcount"
+	"github.com/THE108/requestcounter/utils/errors"
+)
+
+const maxKeyLength = 128
+
+func validateKey(key string) error {
+	if key == "" {
+		return errors.New(http.StatusBadRequest, "key is empty")
+	}
+
+	if len(key) > maxKeyLength {
+		return errors.New(http.StatusBadRequest, "key is too long")
+	}
+
+	return nil
+}
+
+func init() {
+	errors.Register(requestcount.ErrUnknownKey, http.StatusNotFound, "unknown_key")
+	errors.Register(requestcount.ErrArchiveDisabled, http.StatusNotFound, "archive_disabled")
+	errors.Register(requestcount.ErrAnomalyDisabled, http.StatusNotFound, "anomaly_detection_disabled")
+	errors.Register(requestcount.ErrTooManyKeys, http.StatusTooManyRequests, "too_many_keys")
+	errors.Register(requestcount.ErrTooManySeries, http.StatusTooManyRequests, "too_many_series")
+	errors.Register(requestcount.ErrInvalidStatusClass, http.StatusBadRequest, "invalid_status_class")
+	errors.Register(requestcount.ErrInvalidLabels, http.StatusBadRequest, "invalid_labels")
+	errors.Register(requestcount.ErrReservedName, http.StatusBadRequest, "reserved_name")
+	errors.Register(requestcount.ErrCounterHasNoLabels, http.StatusBadRequest, "counter_has_no_labels")
+	errors.Register(requestcount.ErrTooManySubscribers, http.StatusServiceUnavailable, "too_many_subscribers")
+	errors.Register(requestcount.ErrClosed, http.StatusServiceUnavailable, "closed")
+}
+
+// wrapModelError maps errors of the model registered above to status codes, unknown errors to 500
+func wrapModelError(err error) error {
+	return errors.From(err)
+}
@@ -0,0 +1,45 @@
+package requestcount
+
+import (
+	"net/http"
+
+	"github.com/THE108/requestcounter/models/requestcount"
+	"github.com/THE108/requestcounter/utils/errors"
+	"github.com/THE108/requestcounter/utils/params"
+
+	"golang.org/x/net/context"
+)
+
+const maxQueryLength = 4096
+
+type IEvaluator interface {
+	Eval(ctx context.Context, q string) (*requestcount.EvalResult, error)
+}
+
+type GetEvalHandler struct {
+	model IEvaluator
+}
+
+func NewGetEvalHandler(model IEvaluator) *GetEvalHandler {
+	return &GetEvalHandler{
+		model: model,
+	}
+}
+
+func (handler *GetEvalHandler) Process(ctx context.Context, params params.Params) (interface{}, error) {
+	q, err := params.String("q", true)
+	if err != nil {
+		return nil, errors.Wrap(err, http.StatusBadRequest)
+	}
+
+	if len(q) > maxQueryLength {
+		return nil, errors.New(http.StatusBadRequest, "query is too long")
+	}
+
+	result, err := handler.model.Eval(ctx, q)
+	if err != nil {
+		return nil, wrapModelError(err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,49 @@
+package requestcount
+
+import (
+	"net/http"
+
+	"github.com/THE108/requestcounter/models/requestcount"
+	"github.com/THE108/requestcounter/utils/errors"
+	"github.com/THE108/requestcounter/utils/params"
+
+	"golang.org/x/net/context"
+)
+
+const maxEventsBatchSize = 10000
+
+type IEventsAdder interface {
+	AddEvents(ctx context.Context, events []requestcount.Event) (*requestcount.EventsResult, error)
+}
+
+type PostEventsHandler struct {
+	model IEventsAdder
+}
+
+func NewPostEventsHandler(model IEventsAdder) *PostEventsHandler {
+	return &PostEventsHandler{
+		model: model,
+	}
+}
+
+func (handler *PostEventsHandler) GetBuffer() interface{} {
+	return &[]requestcount.Event{}
+}
+
+func (handler *PostEventsHandler) GetOutput() interface{} {
+	return &requestcount.EventsResult{}
+}
+
+func (handler *PostEventsHandler) Process(ctx context.Context, data interface{}, _ params.Params) (interface{}, error) {
+	events := *data.(*[]requestcount.Event)
+	if len(events) > maxEventsBatchSize {
+		return nil, errors.New(http.StatusBadRequest, "too many events in batch")
+	}
+
+	result, err := handler.model.AddEvents(ctx, events)
+	if err != nil {
+		return nil, wrapModelError(err)
+	}
+
+	return result, nil
+}
@@ -21,6 +21,10 @@ func NewGetRecipeHandler(model IRequestCountGetter) *GetRequestCountHandler {
 	}
 }
 
+func (handler *GetRequestCountHandler) GetOutput() interface{} {
+	return &requestcount.RequestCount{}
+}
+
 func (handler *GetRequestCountHandler) Process(ctx context.Context, _ params.Params) (interface{}, error) {
 	return handler.model.Get(ctx), nil
 }
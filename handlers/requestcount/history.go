@@ -0,0 +1,70 @@
+package requestcount
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/THE108/requestcounter/models/requestcount"
+	"github.com/THE108/requestcounter/utils/errors"
+	"github.com/THE108/requestcounter/utils/params"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	defaultHistoryPeriod = time.Hour
+	defaultHistoryStep   = time.Minute
+	maxHistoryPoints     = 10000
+)
+
+type IRequestCountHistoryGetter interface {
+	History(ctx context.Context, from, to time.Time, step time.Duration) (*requestcount.History, error)
+}
+
+type GetHistoryHandler struct {
+	model IRequestCountHistoryGetter
+	now   func() time.Time
+}
+
+func NewGetHistoryHandler(model IRequestCountHistoryGetter) *GetHistoryHandler {
+	return &GetHistoryHandler{
+		model: model,
+		now:   time.Now,
+	}
+}
+
+func (handler *GetHistoryHandler) Process(ctx context.Context, params params.Params) (interface{}, error) {
+	to, err := params.Time("to", false, handler.now())
+	if err != nil {
+		return nil, errors.Wrap(err, http.StatusBadRequest)
+	}
+
+	from, err := params.Time("from", false, to.Add(-defaultHistoryPeriod))
+	if err != nil {
+		return nil, errors.Wrap(err, http.StatusBadRequest)
+	}
+
+	step, err := params.Duration("step", false, defaultHistoryStep)
+	if err != nil {
+		return nil, errors.Wrap(err, http.StatusBadRequest)
+	}
+
+	if !from.Before(to) {
+		return nil, errors.New(http.StatusBadRequest, "from must be before to")
+	}
+
+	if step <= 0 {
+		return nil, errors.New(http.StatusBadRequest, "step must be positive")
+	}
+
+	if to.Sub(from)/step >= maxHistoryPoints {
+		return nil, errors.New(http.StatusBadRequest, "too many points requested, increase step")
+	}
+
+	history, err := handler.model.History(ctx, from, to, step)
+	if err != nil {
+		return nil, wrapModelError(err)
+	}
+
+	return history, nil
+}
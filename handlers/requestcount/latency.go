@@ -0,0 +1,45 @@
+package requestcount
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/THE108/requestcounter/models/requestcount"
+	"github.com/THE108/requestcounter/utils/errors"
+	"github.com/THE108/requestcounter/utils/params"
+
+	"golang.org/x/net/context"
+)
+
+type ILatencyGetter interface {
+	Latency(ctx context.Context, handler string, window time.Duration) (*requestcount.LatencyStats, error)
+}
+
+type GetLatencyHandler struct {
+	model ILatencyGetter
+}
+
+func NewGetLatencyHandler(model ILatencyGetter) *GetLatencyHandler {
+	return &GetLatencyHandler{
+		model: model,
+	}
+}
+
+func (handler *GetLatencyHandler) Process(ctx context.Context, params params.Params) (interface{}, error) {
+	name, err := params.String("handler", true)
+	if err != nil {
+		return nil, errors.Wrap(err, http.StatusBadRequest)
+	}
+
+	window, err := params.Duration("window", false)
+	if err != nil {
+		return nil, errors.Wrap(err, http.StatusBadRequest)
+	}
+
+	result, err := handler.model.Latency(ctx, name, window)
+	if err != nil {
+		return nil, wrapModelError(err)
+	}
+
+	return result, nil
+}
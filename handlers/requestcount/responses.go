@@ -0,0 +1,55 @@
+package requestcount
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/THE108/requestcounter/models/requestcount"
+	"github.com/THE108/requestcounter/utils/errors"
+	"github.com/THE108/requestcounter/utils/params"
+
+	"golang.org/x/net/context"
+)
+
+type IResponsesGetter interface {
+	Responses(ctx context.Context, filter requestcount.ResponseFilter, window time.Duration) (*requestcount.ResponseStats, error)
+}
+
+type GetResponsesHandler struct {
+	model IResponsesGetter
+}
+
+func NewGetResponsesHandler(model IResponsesGetter) *GetResponsesHandler {
+	return &GetResponsesHandler{
+		model: model,
+	}
+}
+
+func (handler *GetResponsesHandler) Process(ctx context.Context, params params.Params) (interface{}, error) {
+	var filter requestcount.ResponseFilter
+	var err error
+
+	if filter.Status, err = params.String("status", false); err != nil {
+		return nil, errors.Wrap(err, http.StatusBadRequest)
+	}
+
+	if filter.Method, err = params.String("method", false); err != nil {
+		return nil, errors.Wrap(err, http.StatusBadRequest)
+	}
+
+	if filter.Route, err = params.String("route", false); err != nil {
+		return nil, errors.Wrap(err, http.StatusBadRequest)
+	}
+
+	window, err := params.Duration("window", false)
+	if err != nil {
+		return nil, errors.Wrap(err, http.StatusBadRequest)
+	}
+
+	result, err := handler.model.Responses(ctx, filter, window)
+	if err != nil {
+		return nil, wrapModelError(err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,104 @@
+package requestcount
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/THE108/requestcounter/models/requestcount"
+	"github.com/THE108/requestcounter/utils/errors"
+	"github.com/THE108/requestcounter/utils/params"
+
+	"golang.org/x/net/context"
+)
+
+type ISeriesCounter interface {
+	AddSeries(ctx context.Context, name string, labels requestcount.Labels, n uint64) error
+	QuerySeries(ctx context.Context, name string, filter requestcount.Labels, sumBy []string, window time.Duration) (*requestcount.SeriesQueryResult, error)
+}
+
+type SeriesIncrement struct {
+	Name   string              `json:"name"`
+	Labels requestcount.Labels `json:"labels"`
+	Count  uint64              `json:"count"`
+}
+
+type PostSeriesHandler struct {
+	model ISeriesCounter
+}
+
+func NewPostSeriesHandler(model ISeriesCounter) *PostSeriesHandler {
+	return &PostSeriesHandler{
+		model: model,
+	}
+}
+
+func (handler *PostSeriesHandler) GetBuffer() interface{} {
+	return &SeriesIncrement{}
+}
+
+func (handler *PostSeriesHandler) GetOutput() interface{} {
+	return &requestcount.SeriesQueryResult{}
+}
+
+func (handler *PostSeriesHandler) Process(ctx context.Context, data interface{}, _ params.Params) (interface{}, error) {
+	increment := data.(*SeriesIncrement)
+	if err := validateKey(increment.Name); err != nil {
+		return nil, err
+	}
+
+	// count could be omitted to increment by one
+	if increment.Count == 0 {
+		increment.Count = 1
+	}
+
+	if err := handler.model.AddSeries(ctx, increment.Name, increment.Labels, increment.Count); err != nil {
+		return nil, wrapModelError(err)
+	}
+
+	result, err := handler.model.QuerySeries(ctx, increment.Name, increment.Labels, nil, 0)
+	if err != nil {
+		return nil, wrapModelError(err)
+	}
+
+	return result, nil
+}
+
+type GetSeriesQueryHandler struct {
+	model ISeriesCounter
+}
+
+func NewGetSeriesQueryHandler(model ISeriesCounter) *GetSeriesQueryHandler {
+	return &GetSeriesQueryHandler{
+		model: model,
+	}
+}
+
+func (handler *GetSeriesQueryHandler) Process(ctx context.Context, params params.Params) (interface{}, error) {
+	// all labeled series are queried if name is omitted
+	name, err := params.String("name", false)
+	if err != nil {
+		return nil, errors.Wrap(err, http.StatusBadRequest)
+	}
+
+	filter, err := params.Labels("filter", false)
+	if err != nil {
+		return nil, errors.Wrap(err, http.StatusBadRequest)
+	}
+
+	sumBy, err := params.Strings("sum_by", false)
+	if err != nil {
+		return nil, errors.Wrap(err, http.StatusBadRequest)
+	}
+
+	window, err := params.Duration("window", false)
+	if err != nil {
+		return nil, errors.Wrap(err, http.StatusBadRequest)
+	}
+
+	result, err := handler.model.QuerySeries(ctx, name, filter, sumBy, window)
+	if err != nil {
+		return nil, wrapModelError(err)
+	}
+
+	return result, nil
+}
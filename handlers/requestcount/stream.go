@@ -0,0 +1,113 @@
+package requestcount
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/THE108/requestcounter/models/requestcount"
+	"github.com/THE108/requestcounter/utils/archive"
+	"github.com/THE108/requestcounter/utils/errors"
+	"github.com/THE108/requestcounter/utils/params"
+	"github.com/THE108/requestcounter/utils/stream"
+
+	"golang.org/x/net/context"
+)
+
+const minStreamInterval = 100 * time.Millisecond
+
+type IRequestCountStreamer interface {
+	Peek() *requestcount.RequestCount
+	Subscribe() (<-chan archive.Record, func(), error)
+}
+
+type Bucket struct {
+	Start    time.Time `json:"start"`
+	Duration string    `json:"duration"`
+	Count    uint64    `json:"count"`
+}
+
+type StreamEvent struct {
+	*requestcount.RequestCount
+	Completed *Bucket `json:"completed,omitempty"`
+}
+
+type GetStreamHandler struct {
+	model IRequestCountStreamer
+}
+
+func NewGetStreamHandler(model IRequestCountStreamer) *GetStreamHandler {
+	return &GetStreamHandler{
+		model: model,
+	}
+}
+
+// Stream sends the window count on connect and then on every shift of the ring,
+// or every interval if it is requested
+func (handler *GetStreamHandler) Stream(ctx context.Context, params params.Params, stream stream.IStream) error {
+	interval, err := params.Duration("interval", false, 0)
+	if err != nil {
+		return errors.Wrap(err, http.StatusBadRequest)
+	}
+
+	if interval != 0 && interval < minStreamInterval {
+		return errors.New(http.StatusBadRequest, "interval must be at least "+minStreamInterval.String())
+	}
+
+	withCompleted, err := params.Bool("completed", false, false)
+	if err != nil {
+		return errors.Wrap(err, http.StatusBadRequest)
+	}
+
+	shifts, unsubscribe, err := handler.model.Subscribe()
+	if err != nil {
+		return wrapModelError(err)
+	}
+	defer unsubscribe()
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	var completed *Bucket
+	send := true
+	for {
+		if send {
+			count := handler.model.Peek()
+			if count == nil {
+				return requestcount.ErrClosed
+			}
+
+			event := &StreamEvent{RequestCount: count}
+			if withCompleted {
+				event.Completed = completed
+			}
+
+			if err := stream.Send("count", event); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case rec, ok := <-shifts:
+			if !ok {
+				return requestcount.ErrClosed
+			}
+
+			completed = &Bucket{
+				Start:    rec.Start,
+				Duration: rec.Duration.String(),
+				Count:    rec.Count,
+			}
+
+			// with requested interval shifts only update the completed interval
+			send = tick == nil
+		case <-tick:
+			send = true
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package requestcount
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/THE108/requestcounter/models/requestcount"
+	"github.com/THE108/requestcounter/utils/errors"
+	"github.com/THE108/requestcounter/utils/params"
+
+	"golang.org/x/net/context"
+)
+
+type IValueCounter interface {
+	Observe(ctx context.Context, name string, value uint64) (*requestcount.ValueStats, error)
+	Values(ctx context.Context, name string, window time.Duration) (*requestcount.ValueStats, error)
+}
+
+type ValueObservation struct {
+	Name  string `json:"name"`
+	Value uint64 `json:"value"`
+}
+
+type PostValuesHandler struct {
+	model IValueCounter
+}
+
+func NewPostValuesHandler(model IValueCounter) *PostValuesHandler {
+	return &PostValuesHandler{
+		model: model,
+	}
+}
+
+func (handler *PostValuesHandler) GetBuffer() interface{} {
+	return &ValueObservation{}
+}
+
+func (handler *PostValuesHandler) GetOutput() interface{} {
+	return &requestcount.ValueStats{}
+}
+
+func (handler *PostValuesHandler) Process(ctx context.Context, data interface{}, _ params.Params) (interface{}, error) {
+	observation := data.(*ValueObservation)
+	if err := validateKey(observation.Name); err != nil {
+		return nil, err
+	}
+
+	result, err := handler.model.Observe(ctx, observation.Name, observation.Value)
+	if err != nil {
+		return nil, wrapModelError(err)
+	}
+
+	return result, nil
+}
+
+type GetValuesHandler struct {
+	model IValueCounter
+}
+
+func NewGetValuesHandler(model IValueCounter) *GetValuesHandler {
+	return &GetValuesHandler{
+		model: model,
+	}
+}
+
+func (handler *GetValuesHandler) Process(ctx context.Context, params params.Params) (interface{}, error) {
+	name, err := params.String("name", true)
+	if err != nil {
+		return nil, errors.Wrap(err, http.StatusBadRequest)
+	}
+
+	window, err := params.Duration("window", false)
+	if err != nil {
+		return nil, errors.Wrap(err, http.StatusBadRequest)
+	}
+
+	result, err := handler.model.Values(ctx, name, window)
+	if err != nil {
+		return nil, wrapModelError(err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,77 @@
+package requestcount
+
+import (
+	"net/http"
+
+	"github.com/THE108/requestcounter/models/requestcount"
+	"github.com/THE108/requestcounter/utils/errors"
+	"github.com/THE108/requestcounter/utils/params"
+
+	"golang.org/x/net/context"
+)
+
+type IWeightedCounter interface {
+	Add(ctx context.Context, key string, n uint64) (*requestcount.WeightedCount, error)
+	Weighted(ctx context.Context, key string) (*requestcount.WeightedCount, error)
+}
+
+type WeightedIncrement struct {
+	Key   string `json:"key"`
+	Value uint64 `json:"value"`
+}
+
+type PostWeightedHandler struct {
+	model IWeightedCounter
+}
+
+func NewPostWeightedHandler(model IWeightedCounter) *PostWeightedHandler {
+	return &PostWeightedHandler{
+		model: model,
+	}
+}
+
+func (handler *PostWeightedHandler) GetBuffer() interface{} {
+	return &WeightedIncrement{}
+}
+
+func (handler *PostWeightedHandler) GetOutput() interface{} {
+	return &requestcount.WeightedCount{}
+}
+
+func (handler *PostWeightedHandler) Process(ctx context.Context, data interface{}, _ params.Params) (interface{}, error) {
+	increment := data.(*WeightedIncrement)
+	if err := validateKey(increment.Key); err != nil {
+		return nil, err
+	}
+
+	result, err := handler.model.Add(ctx, increment.Key, increment.Value)
+	if err != nil {
+		return nil, wrapModelError(err)
+	}
+
+	return result, nil
+}
+
+type GetWeightedHandler struct {
+	model IWeightedCounter
+}
+
+func NewGetWeightedHandler(model IWeightedCounter) *GetWeightedHandler {
+	return &GetWeightedHandler{
+		model: model,
+	}
+}
+
+func (handler *GetWeightedHandler) Process(ctx context.Context, params params.Params) (interface{}, error) {
+	key, err := params.String("key", true)
+	if err != nil {
+		return nil, errors.Wrap(err, http.StatusBadRequest)
+	}
+
+	result, err := handler.model.Weighted(ctx, key)
+	if err != nil {
+		return nil, wrapModelError(err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,254 @@
+package alerting
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/THE108/requestcounter/utils/archive"
+	"github.com/THE108/requestcounter/utils/log"
+	"github.com/THE108/requestcounter/utils/query"
+)
+
+// Alert states
+const (
+	StateInactive = "inactive"
+	StatePending  = "pending"
+	StateFiring   = "firing"
+	StateResolved = "resolved"
+)
+
+var comparators = map[string]func(value, threshold float64) bool{
+	">":  func(value, threshold float64) bool { return value > threshold },
+	">=": func(value, threshold float64) bool { return value >= threshold },
+	"<":  func(value, threshold float64) bool { return value < threshold },
+	"<=": func(value, threshold float64) bool { return value <= threshold },
+	"==": func(value, threshold float64) bool { return value == threshold },
+	"!=": func(value, threshold float64) bool { return value != threshold },
+}
+
+// Rule describes condition of an alert.
+// Counter is a query expression, its counters without range are evaluated during Window.
+type Rule struct {
+	Name       string
+	Counter    string
+	Window     time.Duration
+	Comparator string
+	Threshold  float64
+	For        time.Duration
+	Webhook    string
+}
+
+type Config struct {
+	Rules          []Rule
+	Source         query.ISource
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Timeout        time.Duration
+	Logger         log.ILogger
+}
+
+// Alert is a state of a rule
+type Alert struct {
+	Rule        string     `json:"rule"`
+	Counter     string     `json:"counter"`
+	Window      string     `json:"window"`
+	Comparator  string     `json:"comparator"`
+	Threshold   float64    `json:"threshold"`
+	State       string     `json:"state"`
+	Value       float64    `json:"value"`
+	ActiveSince *time.Time `json:"active_since,omitempty"`
+	Timestamp   time.Time  `json:"timestamp"`
+}
+
+type rule struct {
+	Rule
+	expr        *query.Expr
+	compare     func(value, threshold float64) bool
+	state       string
+	value       float64
+	activeSince time.Time
+	evaluatedAt time.Time
+}
+
+func (r *rule) alert() Alert {
+	alert := Alert{
+		Rule:       r.Name,
+		Counter:    r.Counter,
+		Window:     r.Window.String(),
+		Comparator: r.Comparator,
+		Threshold:  r.Threshold,
+		State:      r.state,
+		Value:      r.value,
+		Timestamp:  r.evaluatedAt,
+	}
+
+	if r.state == StatePending || r.state == StateFiring {
+		activeSince := r.activeSince
+		alert.ActiveSince = &activeSince
+	}
+
+	return alert
+}
+
+// Manager evaluates alert rules on every shift of the ring and sends notifications
+// to webhooks when alerts start firing or get resolved
+type Manager struct {
+	mu       sync.Mutex
+	rules    []*rule
+	source   query.ISource
+	notifier *notifier
+	logger   log.ILogger
+	now      func() time.Time
+}
+
+func NewManager(cfg *Config) (*Manager, error) {
+	rules := make([]*rule, 0, len(cfg.Rules))
+	names := make(map[string]struct{}, len(cfg.Rules))
+	for _, r := range cfg.Rules {
+		if r.Name == "" {
+			return nil, fmt.Errorf("alert rule of counter %s has no name", r.Counter)
+		}
+
+		if _, ok := names[r.Name]; ok {
+			return nil, fmt.Errorf("duplicate alert rule %s", r.Name)
+		}
+		names[r.Name] = struct{}{}
+
+		if r.Window <= 0 {
+			return nil, fmt.Errorf("window of alert rule %s must be positive", r.Name)
+		}
+
+		// the ring keeps counts only of its period
+		if period := cfg.Source.Period(); r.Window > period {
+			return nil, fmt.Errorf("window of alert rule %s exceeds counter period %s", r.Name, period)
+		}
+
+		expr, err := query.Parse(r.Counter)
+		if err != nil {
+			return nil, fmt.Errorf("error parse counter of alert rule %s: %s", r.Name, err.Error())
+		}
+
+		compare, ok := comparators[r.Comparator]
+		if !ok {
+			return nil, fmt.Errorf("unknown comparator of alert rule %s: %q", r.Name, r.Comparator)
+		}
+
+		rules = append(rules, &rule{
+			Rule:    r,
+			expr:    expr,
+			compare: compare,
+			state:   StateInactive,
+		})
+	}
+
+	return &Manager{
+		rules:    rules,
+		source:   cfg.Source,
+		notifier: newNotifier(cfg),
+		logger:   cfg.Logger,
+		now:      time.Now,
+	}, nil
+}
+
+// Run starts sending notifications
+func (m *Manager) Run() error {
+	m.notifier.run()
+	return nil
+}
+
+// Close stops sending notifications, notifications in progress are dropped
+func (m *Manager) Close() error {
+	m.notifier.close()
+	return nil
+}
+
+// OnShift evaluates rules when the ring is shifted
+func (m *Manager) OnShift(completed archive.Record) {
+	m.Evaluate()
+}
+
+// Evaluate evaluates all rules and updates their states
+func (m *Manager) Evaluate() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	for _, r := range m.rules {
+		value, err := r.expr.Eval(&windowSource{source: m.source, window: r.Window})
+		if err != nil {
+			m.logger.Errorf("error evaluate alert rule %s: %s", r.Name, err.Error())
+			continue
+		}
+
+		r.value = value
+		r.evaluatedAt = now
+
+		if r.compare(value, r.Threshold) {
+			m.activate(r, now)
+		} else {
+			m.deactivate(r)
+		}
+	}
+}
+
+func (m *Manager) activate(r *rule, now time.Time) {
+	switch r.state {
+	case StateInactive, StateResolved:
+		r.activeSince = now
+		r.state = StatePending
+	case StateFiring:
+		return
+	}
+
+	if now.Sub(r.activeSince) >= r.For {
+		r.state = StateFiring
+		m.logger.Warningf("alert %s is firing: %s = %g", r.Name, r.Counter, r.value)
+		m.notifier.notify(r.Webhook, r.alert())
+	}
+}
+
+func (m *Manager) deactivate(r *rule) {
+	switch r.state {
+	case StatePending:
+		r.state = StateInactive
+	case StateFiring:
+		r.state = StateResolved
+		m.logger.Infof("alert %s is resolved: %s = %g", r.Name, r.Counter, r.value)
+		m.notifier.notify(r.Webhook, r.alert())
+	}
+}
+
+// Alerts returns current states of all rules
+func (m *Manager) Alerts() []Alert {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	alerts := make([]Alert, 0, len(m.rules))
+	for _, r := range m.rules {
+		alerts = append(alerts, r.alert())
+	}
+
+	return alerts
+}
+
+// windowSource evaluates counters without range during window
+type windowSource struct {
+	source query.ISource
+	window time.Duration
+}
+
+func (ws *windowSource) Sum(name string, matchers map[string]string, window time.Duration) (float64, error) {
+	if window == 0 {
+		window = ws.window
+	}
+	return ws.source.Sum(name, matchers, window)
+}
+
+func (ws *windowSource) Period() time.Duration {
+	if ws.window == 0 {
+		return ws.source.Period()
+	}
+	return ws.window
+}
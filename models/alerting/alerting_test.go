@@ -0,0 +1,202 @@
+package alerting
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/THE108/requestcounter/utils/log"
+
+	. "gopkg.in/check.v1"
+)
+
+type AlertingSuite struct{}
+
+var _ = Suite(&AlertingSuite{})
+
+// Hook up gocheck into the "go test" runner.
+func TestStart(t *testing.T) {
+	TestingT(t)
+}
+
+type fakeSource struct {
+	values  map[string]float64
+	windows []time.Duration
+}
+
+func (src *fakeSource) Sum(name string, matchers map[string]string, window time.Duration) (float64, error) {
+	src.windows = append(src.windows, window)
+	return src.values[name], nil
+}
+
+func (src *fakeSource) Period() time.Duration {
+	return time.Hour
+}
+
+// receiver records alerts posted to the webhook, first failures requests respond with 500
+type receiver struct {
+	mu       sync.Mutex
+	failures int
+	requests int
+	alerts   chan Alert
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	r.requests++
+	fail := r.requests <= r.failures
+	r.mu.Unlock()
+
+	if fail {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	var alert Alert
+	if err := json.NewDecoder(req.Body).Decode(&alert); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	r.alerts <- alert
+}
+
+func (r *receiver) receive(c *C) Alert {
+	select {
+	case alert := <-r.alerts:
+		return alert
+	case <-time.After(5 * time.Second):
+		c.Fatal("alert is not received")
+	}
+	return Alert{}
+}
+
+func (suite *AlertingSuite) Test_Transitions(c *C) {
+	recv := &receiver{failures: 2, alerts: make(chan Alert, 10)}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	src := &fakeSource{values: map[string]float64{"errors": 5, "total": 100}}
+	manager, err := NewManager(&Config{
+		Rules: []Rule{{
+			Name:       "error-ratio",
+			Counter:    "errors / total",
+			Window:     time.Minute,
+			Comparator: ">",
+			Threshold:  0.1,
+			For:        2 * time.Second,
+			Webhook:    server.URL,
+		}},
+		Source:         src,
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+		Timeout:        time.Second,
+		Logger:         log.NewDevNullLogger(),
+	})
+	c.Assert(err, IsNil)
+
+	now := time.Unix(1000, 0)
+	manager.now = func() time.Time {
+		return now
+	}
+
+	c.Assert(manager.Run(), IsNil)
+	defer manager.Close()
+
+	state := func() string {
+		return manager.Alerts()[0].State
+	}
+
+	manager.Evaluate()
+	c.Assert(state(), Equals, StateInactive)
+	c.Assert(src.windows, DeepEquals, []time.Duration{time.Minute, time.Minute})
+
+	src.values["errors"] = 20
+	manager.Evaluate()
+	c.Assert(state(), Equals, StatePending)
+
+	now = now.Add(time.Second)
+	manager.Evaluate()
+	c.Assert(state(), Equals, StatePending)
+
+	now = now.Add(time.Second)
+	manager.Evaluate()
+	c.Assert(state(), Equals, StateFiring)
+
+	alert := recv.receive(c)
+	c.Assert(alert.Rule, Equals, "error-ratio")
+	c.Assert(alert.State, Equals, StateFiring)
+	c.Assert(alert.Value, Equals, 0.2)
+	c.Assert(alert.ActiveSince.Equal(time.Unix(1000, 0)), Equals, true)
+	recv.mu.Lock()
+	c.Assert(recv.requests, Equals, 3)
+	recv.mu.Unlock()
+
+	src.values["errors"] = 1
+	manager.Evaluate()
+	c.Assert(state(), Equals, StateResolved)
+
+	alert = recv.receive(c)
+	c.Assert(alert.State, Equals, StateResolved)
+	c.Assert(alert.Value, Equals, 0.01)
+	c.Assert(alert.ActiveSince, IsNil)
+}
+
+func (suite *AlertingSuite) Test_InvalidRules(c *C) {
+	src := &fakeSource{}
+
+	_, err := NewManager(&Config{Source: src, Rules: []Rule{{Name: "a", Counter: "errors +", Window: time.Minute, Comparator: ">"}}})
+	c.Assert(err, ErrorMatches, "error parse counter of alert rule a: .*")
+
+	_, err = NewManager(&Config{Source: src, Rules: []Rule{{Name: "b", Counter: "errors", Window: time.Minute, Comparator: "=>"}}})
+	c.Assert(err, ErrorMatches, `unknown comparator of alert rule b: "=>"`)
+
+	_, err = NewManager(&Config{Source: src, Rules: []Rule{{Counter: "errors", Window: time.Minute, Comparator: ">"}}})
+	c.Assert(err, ErrorMatches, "alert rule of counter errors has no name")
+
+	_, err = NewManager(&Config{Source: src, Rules: []Rule{
+		{Name: "c", Counter: "errors", Window: time.Minute, Comparator: ">"},
+		{Name: "c", Counter: "total", Window: time.Minute, Comparator: ">"},
+	}})
+	c.Assert(err, ErrorMatches, "duplicate alert rule c")
+
+	_, err = NewManager(&Config{Source: src, Rules: []Rule{{Name: "d", Counter: "errors", Comparator: ">"}}})
+	c.Assert(err, ErrorMatches, "window of alert rule d must be positive")
+
+	_, err = NewManager(&Config{Source: src, Rules: []Rule{{Name: "e", Counter: "errors", Window: 2 * time.Hour, Comparator: ">"}}})
+	c.Assert(err, ErrorMatches, "window of alert rule e exceeds counter period 1h0m0s")
+
+	_, err = NewManager(&Config{Source: src, Rules: []Rule{{Name: "f", Counter: "errors", Window: time.Hour, Comparator: ">"}}})
+	c.Assert(err, IsNil)
+}
+
+func (suite *AlertingSuite) Test_WebhooksAreIndependent(c *C) {
+	failing := &receiver{failures: 100, alerts: make(chan Alert, 10)}
+	failingServer := httptest.NewServer(failing)
+	defer failingServer.Close()
+
+	recv := &receiver{alerts: make(chan Alert, 10)}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	n := newNotifier(&Config{
+		Rules:          []Rule{{Name: "a", Webhook: failingServer.URL}, {Name: "b", Webhook: server.URL}},
+		MaxAttempts:    3,
+		InitialBackoff: time.Hour,
+		MaxBackoff:     time.Hour,
+		Timeout:        time.Second,
+		Logger:         log.NewDevNullLogger(),
+	})
+	n.run()
+	defer n.close()
+
+	// the failing webhook waits for a retry while the other one is notified
+	n.notify(failingServer.URL, Alert{Rule: "a"})
+	n.notify(server.URL, Alert{Rule: "b"})
+
+	c.Assert(recv.receive(c).Rule, Equals, "b")
+}
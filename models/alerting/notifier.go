@@ -0,0 +1,142 @@
+package alerting
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/THE108/requestcounter/utils/log"
+)
+
+const notificationQueueSize = 100
+
+type notification struct {
+	webhook string
+	alert   Alert
+}
+
+// notifier posts alerts as JSON to webhooks retrying failed requests with exponential backoff.
+// Every webhook has its own queue and goroutine so retries of a failing webhook do not delay others.
+type notifier struct {
+	queues         map[string]chan notification
+	client         *http.Client
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	done           chan struct{}
+	wg             sync.WaitGroup
+	logger         log.ILogger
+}
+
+func newNotifier(cfg *Config) *notifier {
+	queues := make(map[string]chan notification)
+	for _, r := range cfg.Rules {
+		if _, ok := queues[r.Webhook]; r.Webhook != "" && !ok {
+			queues[r.Webhook] = make(chan notification, notificationQueueSize)
+		}
+	}
+
+	return &notifier{
+		queues:         queues,
+		client:         &http.Client{Timeout: cfg.Timeout},
+		maxAttempts:    cfg.MaxAttempts,
+		initialBackoff: cfg.InitialBackoff,
+		maxBackoff:     cfg.MaxBackoff,
+		done:           make(chan struct{}),
+		logger:         cfg.Logger,
+	}
+}
+
+func (n *notifier) run() {
+	for _, queue := range n.queues {
+		n.wg.Add(1)
+		go n.runSend(queue)
+	}
+}
+
+func (n *notifier) close() {
+	close(n.done)
+	n.wg.Wait()
+}
+
+// notify enqueues notification to the queue of webhook, it is dropped if the queue is full
+func (n *notifier) notify(webhook string, alert Alert) {
+	queue, ok := n.queues[webhook]
+	if !ok {
+		return
+	}
+
+	select {
+	case queue <- notification{webhook: webhook, alert: alert}:
+	default:
+		n.logger.Errorf("alert notification queue is full, notification of %s is dropped", alert.Rule)
+	}
+}
+
+func (n *notifier) runSend(queue chan notification) {
+	defer n.wg.Done()
+	for {
+		select {
+		case item := <-queue:
+			n.logger.ErrorIfNotNil("error send alert notification:", n.send(item))
+		case <-n.done:
+			n.logger.Debug("alert notifier is done")
+			return
+		}
+	}
+}
+
+func (n *notifier) send(item notification) error {
+	body, err := json.Marshal(item.alert)
+	if err != nil {
+		return err
+	}
+
+	backoff := n.initialBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := n.post(item.webhook, body)
+		if err == nil {
+			return nil
+		}
+
+		if !retry || attempt >= n.maxAttempts {
+			return fmt.Errorf("%s: %s (attempts: %d)", item.alert.Rule, err.Error(), attempt)
+		}
+
+		n.logger.Warningf("error send alert notification %s, retry in %s: %s", item.alert.Rule, backoff, err.Error())
+
+		select {
+		case <-time.After(backoff):
+		case <-n.done:
+			return fmt.Errorf("%s: notifier is closed", item.alert.Rule)
+		}
+
+		backoff *= 2
+		if backoff > n.maxBackoff {
+			backoff = n.maxBackoff
+		}
+	}
+}
+
+// post sends body to webhook, returns whether failed request should be retried
+func (n *notifier) post(webhook string, body []byte) (bool, error) {
+	resp, err := n.client.Post(webhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+}
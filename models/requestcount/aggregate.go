@@ -0,0 +1,106 @@
+package requestcount
+
+import (
+	"math"
+)
+
+// aggregate fields offsets in a bucket
+const (
+	aggregateCount = iota
+	aggregateSum
+	aggregateMin
+	aggregateMax
+	aggregateFields
+)
+
+type Aggregate struct {
+	Count uint64 `json:"count"`
+	Sum   uint64 `json:"sum"`
+	Min   uint64 `json:"min"`
+	Max   uint64 `json:"max"`
+}
+
+// Mean returns average of observed values
+func (a *Aggregate) Mean() float64 {
+	if a.Count == 0 {
+		return 0
+	}
+	return float64(a.Sum) / float64(a.Count)
+}
+
+// aggregateRing is a ring of buckets with count, sum, min and max of observed values.
+// It is stored as a flat slice of uint64 so it could be backed by mmaped storage.
+// Buckets are aligned with the main ring: bucket i corresponds to counts[i+2].
+type aggregateRing []uint64
+
+func newAggregateRing(length int) aggregateRing {
+	return make(aggregateRing, length*aggregateFields)
+}
+
+func (r aggregateRing) observe(index int, value uint64) {
+	bucket := r[index*aggregateFields : (index+1)*aggregateFields]
+	if bucket[aggregateCount] == 0 || value < bucket[aggregateMin] {
+		bucket[aggregateMin] = value
+	}
+	if bucket[aggregateCount] == 0 || value > bucket[aggregateMax] {
+		bucket[aggregateMax] = value
+	}
+	bucket[aggregateCount] = addSaturating(bucket[aggregateCount], 1)
+	bucket[aggregateSum] = addSaturating(bucket[aggregateSum], value)
+}
+
+func (r aggregateRing) clear(index int) {
+	bucket := r[index*aggregateFields : (index+1)*aggregateFields]
+	for i := range bucket {
+		bucket[i] = 0
+	}
+}
+
+func (r aggregateRing) empty() bool {
+	for i := 0; i < len(r); i += aggregateFields {
+		if r[i+aggregateCount] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// merge merges buckets count buckets back starting from current
+func (r aggregateRing) merge(current, count int) Aggregate {
+	length := len(r) / aggregateFields
+	if count <= 0 || count > length {
+		count = length
+	}
+
+	result := Aggregate{Min: math.MaxUint64}
+	for i := 0; i < count; i++ {
+		index := (current - i + length) % length
+		bucket := r[index*aggregateFields : (index+1)*aggregateFields]
+		if bucket[aggregateCount] == 0 {
+			continue
+		}
+
+		result.Count = addSaturating(result.Count, bucket[aggregateCount])
+		result.Sum = addSaturating(result.Sum, bucket[aggregateSum])
+		if bucket[aggregateMin] < result.Min {
+			result.Min = bucket[aggregateMin]
+		}
+		if bucket[aggregateMax] > result.Max {
+			result.Max = bucket[aggregateMax]
+		}
+	}
+
+	if result.Count == 0 {
+		result.Min = 0
+	}
+
+	return result
+}
+
+// addSaturating returns a + b or math.MaxUint64 if the sum overflows
+func addSaturating(a, b uint64) uint64 {
+	if sum := a + b; sum >= a {
+		return sum
+	}
+	return math.MaxUint64
+}
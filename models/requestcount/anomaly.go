@@ -0,0 +1,279 @@
+package requestcount
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/THE108/requestcounter/utils/archive"
+	"github.com/THE108/requestcounter/utils/metrics"
+
+	"golang.org/x/net/context"
+)
+
+// Anomaly detection methods
+const (
+	AnomalyZScore = "zscore"
+	AnomalyMAD    = "mad"
+)
+
+// Anomaly kinds
+const (
+	AnomalySpike = "spike"
+	AnomalyDrop  = "drop"
+)
+
+// minimal count of completed intervals in the baseline to calculate a score
+const anomalyMinSamples = 3
+
+// madScale makes median absolute deviation consistent with standard deviation of normal distribution
+const madScale = 1.4826
+
+var (
+	ErrAnomalyDisabled      = errors.New("anomaly detection is disabled")
+	ErrUnknownAnomalyMethod = errors.New("unknown anomaly detection method")
+	ErrInvalidAnomalyWindow = errors.New("anomaly detection window must be positive")
+)
+
+type AnomalyConfig struct {
+	// Method is AnomalyZScore (rolling mean and standard deviation) or AnomalyMAD (median and median absolute deviation)
+	Method string
+	// Window is a count of the last completed intervals forming the baseline
+	Window int
+	// Threshold is a minimal absolute score of an anomaly
+	Threshold float64
+	// MaxEvents is a count of the last anomalies kept in memory
+	MaxEvents int
+}
+
+type Anomaly struct {
+	Start    time.Time `json:"start"`
+	Duration string    `json:"duration"`
+	Count    uint64    `json:"count"`
+	Baseline float64   `json:"baseline"`
+	Score    float64   `json:"score"`
+	Kind     string    `json:"kind"`
+}
+
+type Anomalies struct {
+	Method    string    `json:"method"`
+	Threshold float64   `json:"threshold"`
+	Score     float64   `json:"score"`
+	Events    []Anomaly `json:"events"`
+}
+
+func (a *Anomalies) CSV() [][]string {
+	records := [][]string{{"start", "duration", "count", "baseline", "score", "kind"}}
+	for _, event := range a.Events {
+		records = append(records, []string{
+			event.Start.Format(time.RFC3339Nano),
+			event.Duration,
+			strconv.FormatUint(event.Count, 10),
+			strconv.FormatFloat(event.Baseline, 'g', -1, 64),
+			strconv.FormatFloat(event.Score, 'g', -1, 64),
+			event.Kind,
+		})
+	}
+	return records
+}
+
+// anomalyDetector scores every completed interval against the baseline of previous ones
+type anomalyDetector struct {
+	cfg     AnomalyConfig
+	history []float64 // ring of counts of the last completed intervals
+	pos     int
+	filled  int
+	score   float64
+	events  []Anomaly
+}
+
+func newAnomalyDetector(cfg *AnomalyConfig) (*anomalyDetector, error) {
+	if cfg.Method != AnomalyZScore && cfg.Method != AnomalyMAD {
+		return nil, ErrUnknownAnomalyMethod
+	}
+
+	if cfg.Window < 1 {
+		return nil, ErrInvalidAnomalyWindow
+	}
+
+	return &anomalyDetector{
+		cfg:     *cfg,
+		history: make([]float64, cfg.Window),
+	}, nil
+}
+
+// openAnomalies creates the detector, outdated is a count of the oldest intervals cleared after downtime
+func (prc *RequestCounter) openAnomalies(outdated int) error {
+	if prc.anomalyConfig == nil {
+		return nil
+	}
+
+	var err error
+	prc.anomalies, err = newAnomalyDetector(prc.anomalyConfig)
+	if err != nil {
+		return err
+	}
+
+	prc.seedAnomalies(outdated)
+
+	return nil
+}
+
+// seedAnomalies fills the baseline with completed intervals of the ring restored from storage
+// in chronological order, the current interval and outdated intervals which were not observed are skipped
+func (prc *RequestCounter) seedAnomalies(outdated int) {
+	if prc.anomalies == nil || prc.counts[1] == 0 {
+		return
+	}
+
+	cur := int(prc.counts[0])
+	for i := outdated + 1; i < prc.intervalCount; i++ {
+		index := (cur + i) % prc.intervalCount
+		prc.anomalies.push(float64(prc.counts[index+2]))
+	}
+}
+
+func (d *anomalyDetector) push(count float64) {
+	if len(d.history) == 0 {
+		return
+	}
+
+	d.history[d.pos] = count
+	d.pos = (d.pos + 1) % len(d.history)
+	if d.filled < len(d.history) {
+		d.filled++
+	}
+}
+
+// observe scores completed interval and records it as an anomaly if the score exceeds threshold
+func (d *anomalyDetector) observe(completed archive.Record) {
+	count := float64(completed.Count)
+
+	d.score = 0
+	if d.filled >= anomalyMinSamples {
+		baseline, score := d.scoreOf(count)
+		d.score = score
+
+		if math.Abs(score) >= d.cfg.Threshold {
+			kind := AnomalySpike
+			if score < 0 {
+				kind = AnomalyDrop
+			}
+
+			d.record(Anomaly{
+				Start:    completed.Start,
+				Duration: completed.Duration.String(),
+				Count:    completed.Count,
+				Baseline: baseline,
+				Score:    score,
+				Kind:     kind,
+			})
+		}
+	}
+
+	d.push(count)
+}
+
+// scoreOf returns the center of the baseline and the score of count.
+// Spread is at least a square root of the center as counts of requests are never less noisy
+// than a Poisson process, this keeps flat baselines from turning any change into an anomaly.
+func (d *anomalyDetector) scoreOf(count float64) (float64, float64) {
+	samples := make([]float64, d.filled)
+	copy(samples, d.history[:d.filled])
+
+	var center, spread float64
+	switch d.cfg.Method {
+	case AnomalyMAD:
+		center = median(samples)
+		for i, v := range samples {
+			samples[i] = math.Abs(v - center)
+		}
+		spread = madScale * median(samples)
+	default:
+		for _, v := range samples {
+			center += v
+		}
+		center /= float64(len(samples))
+
+		for _, v := range samples {
+			spread += (v - center) * (v - center)
+		}
+		spread = math.Sqrt(spread / float64(len(samples)))
+	}
+
+	spread = math.Max(spread, math.Max(math.Sqrt(center), 1))
+
+	return center, (count - center) / spread
+}
+
+func (d *anomalyDetector) record(anomaly Anomaly) {
+	if d.cfg.MaxEvents <= 0 {
+		return
+	}
+
+	if len(d.events) >= d.cfg.MaxEvents {
+		copy(d.events, d.events[1:])
+		d.events = d.events[:len(d.events)-1]
+	}
+
+	d.events = append(d.events, anomaly)
+}
+
+// median sorts values in place
+func median(values []float64) float64 {
+	sort.Float64s(values)
+	n := len(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+	return (values[n/2-1] + values[n/2]) / 2
+}
+
+// Anomalies returns the score of the last completed interval and recorded anomalies from the oldest one
+func (prc *RequestCounter) Anomalies(ctx context.Context) (*Anomalies, error) {
+	if prc.anomalies == nil {
+		return nil, ErrAnomalyDisabled
+	}
+
+	prc.mu.Lock()
+	defer prc.mu.Unlock()
+
+	events := make([]Anomaly, len(prc.anomalies.events))
+	copy(events, prc.anomalies.events)
+
+	return &Anomalies{
+		Method:    prc.anomalies.cfg.Method,
+		Threshold: prc.anomalies.cfg.Threshold,
+		Score:     prc.anomalies.score,
+		Events:    events,
+	}, nil
+}
+
+// anomalyScore returns the current score or nil if detection is disabled, must be called under lock
+func (prc *RequestCounter) anomalyScore() *float64 {
+	if prc.anomalies == nil {
+		return nil
+	}
+
+	score := prc.anomalies.score
+	return &score
+}
+
+func (prc *RequestCounter) writeAnomalyMetrics(w *metrics.Writer) {
+	if prc.anomalies == nil {
+		return
+	}
+
+	prc.mu.Lock()
+	score := prc.anomalies.score
+	events := len(prc.anomalies.events)
+	prc.mu.Unlock()
+
+	w.Header("requestcounter_anomaly_score", metrics.Gauge, "Anomaly score of the last completed interval.")
+	w.Sample("requestcounter_anomaly_score", score)
+
+	w.Header("requestcounter_anomaly_events", metrics.Gauge, "Count of anomalies kept in memory.")
+	w.Sample("requestcounter_anomaly_events", float64(events))
+}
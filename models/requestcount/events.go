@@ -0,0 +1,80 @@
+package requestcount
+
+import (
+	"time"
+
+	"github.com/THE108/requestcounter/utils/log"
+
+	"golang.org/x/net/context"
+)
+
+// Event is a count of requests happened at given time
+type Event struct {
+	Timestamp time.Time `json:"timestamp"`
+	Count     uint64    `json:"count"`
+}
+
+type EventsResult struct {
+	Accepted int    `json:"accepted"`
+	Dropped  int    `json:"dropped"`
+	Count    uint64 `json:"count"`
+}
+
+// AddEvents adds each event into the bucket covering its timestamp.
+// Events older than the ring or newer than now plus future tolerance are dropped.
+func (prc *RequestCounter) AddEvents(ctx context.Context, events []Event) (*EventsResult, error) {
+	result := &EventsResult{}
+	now := prc.now()
+
+	prc.mu.Lock()
+	if prc.closed {
+		prc.mu.Unlock()
+		return nil, ErrClosed
+	}
+
+	for _, event := range events {
+		index, ok := prc.bucketIndex(event.Timestamp, now)
+		if !ok {
+			result.Dropped++
+			continue
+		}
+
+		prc.counts[index] = addSaturating(prc.counts[index], event.Count)
+		if index != int(prc.counts[0])+2 {
+			prc.prevCountsSum = addSaturating(prc.prevCountsSum, event.Count)
+		}
+
+		result.Accepted++
+	}
+
+	result.Count = addSaturating(prc.counts[int(prc.counts[0])+2], prc.prevCountsSum)
+
+	if result.Accepted > 0 {
+		prc.notifyChanged()
+	}
+	prc.mu.Unlock()
+
+	log.GetLoggerFromContext(ctx).Debugf("events accepted: %d dropped: %d", result.Accepted, result.Dropped)
+
+	return result, nil
+}
+
+// bucketIndex returns index in counts of the bucket covering timestamp t
+func (prc *RequestCounter) bucketIndex(t, now time.Time) (int, bool) {
+	if t.After(now.Add(prc.futureTolerance)) {
+		return 0, false
+	}
+
+	current := int(prc.counts[0])
+	start := time.Unix(0, int64(prc.counts[1]))
+	if !t.Before(start) {
+		return current + 2, true
+	}
+
+	back := int((start.Sub(t) + prc.intervalDuration - 1) / prc.intervalDuration)
+	if back >= prc.intervalCount {
+		return 0, false
+	}
+
+	return (current-back+prc.intervalCount)%prc.intervalCount + 2, true
+}
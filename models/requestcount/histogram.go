@@ -0,0 +1,207 @@
+package requestcount
+
+import (
+	"math/bits"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/THE108/requestcounter/utils/metrics"
+
+	"golang.org/x/net/context"
+)
+
+// Latencies are recorded in microseconds into log-linear bins:
+// values below histogramSubBins have a bin each, every next power of two
+// range is split into histogramSubBins linear bins (relative error is below 12.5%).
+// Values above 2^histogramMaxExp microseconds (about 71 minutes) go to the last bin.
+const (
+	histogramSubBits = 3
+	histogramSubBins = 1 << histogramSubBits
+	histogramMaxExp  = 32
+	histogramBins    = (histogramMaxExp - histogramSubBits + 1) * histogramSubBins
+)
+
+var latencyQuantiles = []float64{0.5, 0.95, 0.99}
+
+type LatencyStats struct {
+	Handler string  `json:"handler"`
+	Window  string  `json:"window"`
+	Count   uint64  `json:"count"`
+	P50     float64 `json:"p50_ms"`
+	P95     float64 `json:"p95_ms"`
+	P99     float64 `json:"p99_ms"`
+}
+
+func histogramBin(us uint64) int {
+	if us < histogramSubBins {
+		return int(us)
+	}
+
+	exp := bits.Len64(us) - 1
+	if exp >= histogramMaxExp {
+		return histogramBins - 1
+	}
+
+	return (exp-histogramSubBits+1)*histogramSubBins + int(us>>uint(exp-histogramSubBits)) - histogramSubBins
+}
+
+// histogramBinBounds returns [lower, upper) bounds of bin in microseconds
+func histogramBinBounds(bin int) (uint64, uint64) {
+	if bin < histogramSubBins {
+		return uint64(bin), uint64(bin) + 1
+	}
+
+	group := uint(bin / histogramSubBins)
+	offset := uint64(bin % histogramSubBins)
+	lower := (histogramSubBins + offset) << (group - 1)
+	return lower, lower + 1<<(group-1)
+}
+
+// histogramRing is a ring of latency histograms aligned with the main ring:
+// bins of interval i are stored in [i*histogramBins, (i+1)*histogramBins)
+type histogramRing []uint64
+
+func newHistogramRing(length int) histogramRing {
+	return make(histogramRing, length*histogramBins)
+}
+
+func (r histogramRing) observe(index int, d time.Duration) {
+	us := d / time.Microsecond
+	if us < 0 {
+		us = 0
+	}
+	bin := index*histogramBins + histogramBin(uint64(us))
+	r[bin] = addSaturating(r[bin], 1)
+}
+
+func (r histogramRing) clear(index int) {
+	bins := r[index*histogramBins : (index+1)*histogramBins]
+	for i := range bins {
+		bins[i] = 0
+	}
+}
+
+// merge sums histograms of count intervals back starting from current
+func (r histogramRing) merge(current, count int) []uint64 {
+	length := len(r) / histogramBins
+	result := make([]uint64, histogramBins)
+	for i := 0; i < count; i++ {
+		index := (current - i + length) % length
+		for bin, cnt := range r[index*histogramBins : (index+1)*histogramBins] {
+			result[bin] = addSaturating(result[bin], cnt)
+		}
+	}
+	return result
+}
+
+// histogramQuantiles returns total count and estimated quantiles in milliseconds
+func histogramQuantiles(bins []uint64, quantiles []float64) (uint64, []float64) {
+	var total uint64
+	for _, cnt := range bins {
+		total = addSaturating(total, cnt)
+	}
+
+	result := make([]float64, len(quantiles))
+	if total == 0 {
+		return total, result
+	}
+
+	for i, q := range quantiles {
+		rank := uint64(q*float64(total) + 0.5)
+		if rank == 0 {
+			rank = 1
+		}
+
+		var seen uint64
+		for bin, cnt := range bins {
+			seen += cnt
+			if seen >= rank {
+				lower, upper := histogramBinBounds(bin)
+				result[i] = float64(lower+upper) / 2 / 1000
+				break
+			}
+		}
+	}
+
+	return total, result
+}
+
+// ObserveLatency records request latency of handler into the current interval
+func (prc *RequestCounter) ObserveLatency(handler string, d time.Duration) {
+	prc.mu.Lock()
+	defer prc.mu.Unlock()
+
+	if prc.closed {
+		return
+	}
+
+	ring, ok := prc.histograms[handler]
+	if !ok {
+		ring = newHistogramRing(prc.intervalCount)
+		prc.histograms[handler] = ring
+	}
+
+	ring.observe(int(prc.counts[0]), d)
+}
+
+// Latency returns latency quantiles of handler during window.
+// Zero window means the whole time period.
+func (prc *RequestCounter) Latency(ctx context.Context, handler string, window time.Duration) (*LatencyStats, error) {
+	prc.mu.Lock()
+	if prc.closed {
+		prc.mu.Unlock()
+		return nil, ErrClosed
+	}
+
+	ring, ok := prc.histograms[handler]
+	if !ok {
+		prc.mu.Unlock()
+		return nil, ErrUnknownKey
+	}
+
+	buckets := prc.windowBuckets(window)
+	bins := ring.merge(int(prc.counts[0]), buckets)
+	prc.mu.Unlock()
+
+	count, quantiles := histogramQuantiles(bins, latencyQuantiles)
+
+	return &LatencyStats{
+		Handler: handler,
+		Window:  (prc.intervalDuration * time.Duration(buckets)).String(),
+		Count:   count,
+		P50:     quantiles[0],
+		P95:     quantiles[1],
+		P99:     quantiles[2],
+	}, nil
+}
+
+func (prc *RequestCounter) clearHistograms(index int) {
+	for _, ring := range prc.histograms {
+		ring.clear(index)
+	}
+}
+
+func (prc *RequestCounter) writeLatencyMetrics(w *metrics.Writer) {
+	prc.mu.Lock()
+	current := int(prc.counts[0])
+	handlers := make([]string, 0, len(prc.histograms))
+	merged := make(map[string][]uint64, len(prc.histograms))
+	for handler, ring := range prc.histograms {
+		handlers = append(handlers, handler)
+		merged[handler] = ring.merge(current, prc.intervalCount)
+	}
+	prc.mu.Unlock()
+
+	sort.Strings(handlers)
+
+	const name = "requestcounter_request_duration_seconds"
+	w.Header(name, metrics.Summary, "Request latency during the last time period.")
+	for _, handler := range handlers {
+		count, quantiles := histogramQuantiles(merged[handler], latencyQuantiles)
+		for i, q := range latencyQuantiles {
+			w.Sample(name, quantiles[i]/1000, "handler", handler, "quantile", strconv.FormatFloat(q, 'g', -1, 64))
+		}
+		w.Sample(name+"_count", float64(count), "handler", handler)
+	}
+}
@@ -0,0 +1,76 @@
+package requestcount
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/THE108/requestcounter/utils/log"
+
+	"golang.org/x/net/context"
+)
+
+var ErrArchiveDisabled = errors.New("history archive is disabled")
+
+type HistoryPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Count     uint64    `json:"count"`
+}
+
+type History struct {
+	From   time.Time      `json:"from"`
+	To     time.Time      `json:"to"`
+	Step   string         `json:"step"`
+	Points []HistoryPoint `json:"points"`
+}
+
+func (h *History) CSV() [][]string {
+	records := [][]string{{"timestamp", "count"}}
+	for _, point := range h.Points {
+		records = append(records, []string{
+			point.Timestamp.Format(time.RFC3339),
+			strconv.FormatUint(point.Count, 10),
+		})
+	}
+	return records
+}
+
+// History returns archived bucket counts in [from, to) summed into points of step duration
+func (prc *RequestCounter) History(ctx context.Context, from, to time.Time, step time.Duration) (*History, error) {
+	if prc.archive == nil {
+		return nil, ErrArchiveDisabled
+	}
+
+	records, err := prc.archive.Read(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	// reading of many segments could outlive the request
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	log.GetLoggerFromContext(ctx).Debugf("history: %d records read", len(records))
+
+	pointCount := int((to.Sub(from) + step - 1) / step)
+	points := make([]HistoryPoint, pointCount)
+	for i := range points {
+		points[i].Timestamp = from.Add(step * time.Duration(i))
+	}
+
+	for _, rec := range records {
+		i := int(rec.Start.Sub(from) / step)
+		if i < 0 || i >= pointCount {
+			continue
+		}
+		points[i].Count += rec.Count
+	}
+
+	return &History{
+		From:   from,
+		To:     to,
+		Step:   step.String(),
+		Points: points,
+	}, nil
+}
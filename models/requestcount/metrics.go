@@ -0,0 +1,23 @@
+package requestcount
+
+import (
+	"github.com/THE108/requestcounter/utils/metrics"
+)
+
+// WriteMetrics writes request counter metrics in Prometheus text format
+func (prc *RequestCounter) WriteMetrics(w *metrics.Writer) {
+	prc.mu.Lock()
+	if prc.closed {
+		prc.mu.Unlock()
+		return
+	}
+	count := prc.counts[int(prc.counts[0])+2] + prc.prevCountsSum
+	prc.mu.Unlock()
+
+	w.Header("requestcounter_window_requests", metrics.Gauge, "Count of requests during the last time period.")
+	w.Sample("requestcounter_window_requests", float64(count))
+
+	prc.writeLatencyMetrics(w)
+	prc.writeResponsesMetrics(w)
+	prc.writeAnomalyMetrics(w)
+}
@@ -0,0 +1,93 @@
+package requestcount
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/THE108/requestcounter/utils/log"
+	"github.com/THE108/requestcounter/utils/query"
+
+	"golang.org/x/net/context"
+)
+
+// RequestsSeries is a name of the main counter of requests in queries
+const RequestsSeries = "requests"
+
+var ErrCounterHasNoLabels = errors.New("counter has no labels")
+
+type EvalResult struct {
+	Query string  `json:"query"`
+	Value float64 `json:"value"`
+}
+
+func (er *EvalResult) PlainText() string {
+	return strconv.FormatFloat(er.Value, 'g', -1, 64)
+}
+
+// Eval evaluates query expression over counters
+func (prc *RequestCounter) Eval(ctx context.Context, q string) (*EvalResult, error) {
+	expr, err := query.Parse(q)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := expr.Eval(prc)
+	if err != nil {
+		return nil, err
+	}
+
+	log.GetLoggerFromContext(ctx).Debugf("eval: %s = %f", q, value)
+
+	return &EvalResult{
+		Query: q,
+		Value: value,
+	}, nil
+}
+
+// Period returns the whole time period of the ring
+func (prc *RequestCounter) Period() time.Duration {
+	return prc.intervalDuration * time.Duration(prc.intervalCount)
+}
+
+// Sum returns total of counter name during window.
+// Name is resolved in order: requests, responses, labeled series, weighted counters, value counters.
+// Unknown names are evaluated to zero since idle series are removed.
+func (prc *RequestCounter) Sum(name string, matchers map[string]string, window time.Duration) (float64, error) {
+	prc.mu.Lock()
+	defer prc.mu.Unlock()
+
+	if prc.closed {
+		return 0, ErrClosed
+	}
+
+	buckets := prc.windowBuckets(window)
+	current := int(prc.counts[0])
+
+	if name == ResponsesSeries || prc.labeled != nil && len(prc.labeled.match(name, nil)) > 0 {
+		var sum uint64
+		for _, s := range prc.seriesIndex(name).match(name, matchers) {
+			sum = addSaturating(sum, s.ring.sum(current, buckets))
+		}
+		return float64(sum), nil
+	}
+
+	if len(matchers) > 0 {
+		return 0, fmt.Errorf("%w: %s", ErrCounterHasNoLabels, name)
+	}
+
+	if name == RequestsSeries {
+		return float64(counterRing(prc.counts[2:]).sum(current, buckets)), nil
+	}
+
+	if ring, ok := prc.weighted[name]; ok {
+		return float64(ring.merge(current, buckets).Sum), nil
+	}
+
+	if ring, ok := prc.values[name]; ok {
+		return float64(ring.merge(current, buckets).Sum), nil
+	}
+
+	return 0, nil
+}
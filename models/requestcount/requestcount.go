@@ -1,21 +1,55 @@
 package requestcount
 
 import (
+	"errors"
+	"strconv"
 	"sync"
 	"time"
 
+	"github.com/THE108/requestcounter/utils/archive"
 	"github.com/THE108/requestcounter/utils/log"
+	"github.com/THE108/requestcounter/utils/metrics"
 	"github.com/THE108/requestcounter/utils/storage"
+	"github.com/THE108/requestcounter/utils/tracing"
 
 	"golang.org/x/net/context"
 )
 
+var ErrClosed = errors.New("request counter is closed")
+
 type RequestCount struct {
-	Count uint64 `json:"count"`
+	Count        uint64   `json:"count"`
+	AnomalyScore *float64 `json:"anomaly_score,omitempty"`
+	ETag         string   `json:"-"`
+}
+
+func (rc *RequestCount) PlainText() string {
+	return strconv.FormatUint(rc.Count, 10)
 }
 
 type IRequestCounter interface {
 	Get(ctx context.Context) *RequestCount
+	Peek() *RequestCount
+	Wait(ctx context.Context, etag string, timeout time.Duration) *RequestCount
+	Subscribe() (<-chan archive.Record, func(), error)
+	Add(ctx context.Context, key string, n uint64) (*WeightedCount, error)
+	Weighted(ctx context.Context, key string) (*WeightedCount, error)
+	Observe(ctx context.Context, name string, value uint64) (*ValueStats, error)
+	Values(ctx context.Context, name string, window time.Duration) (*ValueStats, error)
+	ObserveLatency(handler string, d time.Duration)
+	Latency(ctx context.Context, handler string, window time.Duration) (*LatencyStats, error)
+	CountResponse(route, method string, status int)
+	Responses(ctx context.Context, filter ResponseFilter, window time.Duration) (*ResponseStats, error)
+	AddSeries(ctx context.Context, name string, labels Labels, n uint64) error
+	QuerySeries(ctx context.Context, name string, filter Labels, sumBy []string, window time.Duration) (*SeriesQueryResult, error)
+	Eval(ctx context.Context, q string) (*EvalResult, error)
+	Sum(name string, matchers map[string]string, window time.Duration) (float64, error)
+	Period() time.Duration
+	AddShiftListener(listener IShiftListener)
+	WriteMetrics(w *metrics.Writer)
+	Anomalies(ctx context.Context) (*Anomalies, error)
+	AddEvents(ctx context.Context, events []Event) (*EventsResult, error)
+	History(ctx context.Context, from, to time.Time, step time.Duration) (*History, error)
 	Run() error
 	Close() error
 }
@@ -26,12 +60,32 @@ type IStorage interface {
 	Flush() error
 }
 
+type IArchive interface {
+	Open() error
+	Append(rec archive.Record) error
+	Read(from, to time.Time) ([]archive.Record, error)
+	Close() error
+}
+
+// IShiftListener is notified after every shift of the ring with the completed interval
+type IShiftListener interface {
+	OnShift(completed archive.Record)
+}
+
 type RequestCounterConfig struct {
 	IntervalCount    int
 	IntervalDuration time.Duration
 	Filename         string
 	Persistent       bool
 	PersistDuration  time.Duration
+	FutureTolerance  time.Duration
+	MaxWeightedKeys  int
+	MaxSeries        int
+	ValueCounters    []string
+	Archive          *archive.Config
+	Anomaly          *AnomalyConfig
+	MaxSubscribers   int
+	Tracer           *tracing.Tracer
 	Logger           log.ILogger
 }
 
@@ -45,19 +99,46 @@ type RequestCounter struct {
 	persistent       bool
 	closed           bool
 	persistDuration  time.Duration
+	futureTolerance  time.Duration
+	weighted         map[string]aggregateRing
+	maxWeightedKeys  int
+	valueNames       []string
+	values           map[string]aggregateRing
+	histograms       map[string]histogramRing
+	responses        *seriesIndex
+	labeled          *seriesIndex
 	done             chan struct{}
 	wg               sync.WaitGroup
 	logger           log.ILogger
+	tracer           *tracing.Tracer
 	storage          IStorage
+	valuesStorage    IStorage
+	archive          IArchive
+	anomalyConfig    *AnomalyConfig
+	anomalies        *anomalyDetector
+	listeners        []IShiftListener
+	changed          chan struct{}
+	changeSeq        uint64
+	epoch            uint64
+	subMu            sync.Mutex
+	subscribers      map[chan archive.Record]struct{}
+	maxSubscribers   int
 	now              func() time.Time
 }
 
 func NewRequestCounter(cfg *RequestCounterConfig) *RequestCounter {
-	var st IStorage
+	var st, valuesSt IStorage
 	if cfg.Persistent {
 		st = storage.NewPersistentStorage()
+		valuesSt = storage.NewPersistentStorage()
 	} else {
 		st = storage.NewInmemoryStorage()
+		valuesSt = storage.NewInmemoryStorage()
+	}
+
+	var arch IArchive
+	if cfg.Archive != nil {
+		arch = archive.NewArchive(cfg.Archive)
 	}
 
 	return &RequestCounter{
@@ -67,12 +148,32 @@ func NewRequestCounter(cfg *RequestCounterConfig) *RequestCounter {
 		filename:         cfg.Filename,
 		persistent:       cfg.Persistent,
 		persistDuration:  cfg.PersistDuration,
+		futureTolerance:  cfg.FutureTolerance,
+		weighted:         make(map[string]aggregateRing),
+		maxWeightedKeys:  cfg.MaxWeightedKeys,
+		valueNames:       cfg.ValueCounters,
+		histograms:       make(map[string]histogramRing),
+		responses:        newSeriesIndex(cfg.IntervalCount, 0),
+		labeled:          newSeriesIndex(cfg.IntervalCount, cfg.MaxSeries),
 		logger:           cfg.Logger,
+		tracer:           cfg.Tracer,
 		storage:          st,
+		valuesStorage:    valuesSt,
+		archive:          arch,
+		anomalyConfig:    cfg.Anomaly,
+		maxSubscribers:   cfg.MaxSubscribers,
 		now:              time.Now,
+		epoch:            uint64(time.Now().UnixNano()),
 	}
 }
 
+// AddShiftListener adds listener notified after every shift of the ring.
+// Listeners are called from the shift goroutine so they should not block.
+// Must be called before Run.
+func (prc *RequestCounter) AddShiftListener(listener IShiftListener) {
+	prc.listeners = append(prc.listeners, listener)
+}
+
 func (prc *RequestCounter) Run() error {
 	// prealloc intervalCount + 2 uint64 values
 	// counts[0] - current index
@@ -85,9 +186,34 @@ func (prc *RequestCounter) Run() error {
 		return err
 	}
 
-	prc.clearOutdated()
+	if err = prc.openValues(); err != nil {
+		prc.storage.Close()
+		return err
+	}
+
+	if prc.archive != nil {
+		if err = prc.archive.Open(); err != nil {
+			prc.closeStorages()
+			return err
+		}
+	}
+
+	outdated := prc.clearOutdated()
 	prc.calculatePrevCountSum()
 
+	// the baseline is seeded after outdated intervals are cleared so counts before downtime are not learned
+	if err = prc.openAnomalies(outdated); err != nil {
+		prc.closeStorages()
+		if prc.archive != nil {
+			prc.archive.Close()
+		}
+		return err
+	}
+
+	if prc.counts[1] == 0 {
+		prc.counts[1] = uint64(prc.now().UnixNano())
+	}
+
 	prc.wg.Add(1)
 	go prc.runShift()
 
@@ -103,66 +229,106 @@ func (prc *RequestCounter) Close() error {
 	close(prc.done)
 	prc.wg.Wait()
 
+	prc.closeSubscribers()
+
 	prc.mu.Lock()
 	defer prc.mu.Unlock()
 
 	prc.closed = true
 
+	if err := prc.closeStorages(); err != nil {
+		return err
+	}
+
+	if prc.archive != nil {
+		if err := prc.archive.Close(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (prc *RequestCounter) closeStorages() error {
 	if err := prc.storage.Close(); err != nil {
 		return err
 	}
 
+	if len(prc.valueNames) > 0 {
+		if err := prc.valuesStorage.Close(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 func (prc *RequestCounter) Get(ctx context.Context) *RequestCount {
-	var count uint64
 	prc.mu.Lock()
 	if prc.closed {
 		prc.mu.Unlock()
 		return nil
 	}
-	index := int(prc.counts[0]) + 2
-	prc.counts[index]++
-	count = prc.counts[index] + prc.prevCountsSum
+	current := int(prc.counts[0]) + 2
+	prc.counts[current] = addSaturating(prc.counts[current], 1)
+	prc.notifyChanged()
+	count := prc.peek()
 	prc.mu.Unlock()
 
-	log.GetLoggerFromContext(ctx).Debugf("count: %d", count)
+	log.GetLoggerFromContext(ctx).Debugf("count: %d", count.Count)
 
-	return &RequestCount{
-		Count: count,
-	}
+	return count
 }
 
-func (prc *RequestCounter) clearOutdated() {
+// clearOutdated clears intervals which passed since the last shift, the oldest first, and returns their count
+func (prc *RequestCounter) clearOutdated() int {
 	prevTimestamp := time.Unix(0, int64(prc.counts[1]))
 	now := prc.now()
-	index := int(prc.counts[0]) + 3
+	index := int(prc.counts[0]) + 1
+	cleared := 0
 	for i := 0; i < prc.intervalCount; i++ {
 		t := prevTimestamp.Add(prc.intervalDuration * time.Duration(i))
 		if t.After(now) {
 			continue
 		}
 
-		prc.counts[index] = 0
+		if index >= prc.intervalCount {
+			index = 0
+		}
+
+		prc.clearBucket(index)
 
 		index++
-		if index >= len(prc.counts) {
-			index = 2
-		}
+		cleared++
 	}
+	return cleared
+}
+
+// clearBucket zeroes interval at index in the main ring and in all secondary counters
+func (prc *RequestCounter) clearBucket(index int) {
+	prc.counts[index+2] = 0
+	prc.clearWeighted(index)
+	prc.clearValues(index)
+	prc.clearHistograms(index)
+	prc.clearSeries(index)
 }
 
 func (prc *RequestCounter) calculatePrevCountSum() {
 	prc.prevCountsSum = 0
 	for _, cnt := range prc.counts[2:] {
-		prc.prevCountsSum += cnt
+		prc.prevCountsSum = addSaturating(prc.prevCountsSum, cnt)
 	}
 }
 
 func (prc *RequestCounter) shift(now time.Time) {
 	prc.mu.Lock()
 
+	completed := prc.completedBucket(now)
+
+	if prc.anomalies != nil {
+		prc.anomalies.observe(completed)
+	}
+
 	// counts[0] - current index
 	prc.counts[0]++
 	if int(prc.counts[0]) >= prc.intervalCount {
@@ -173,11 +339,37 @@ func (prc *RequestCounter) shift(now time.Time) {
 	prc.counts[1] = uint64(now.UnixNano())
 
 	// set current request count to 0
-	prc.counts[int(prc.counts[0])+2] = 0
+	prc.clearBucket(int(prc.counts[0]))
 
 	prc.calculatePrevCountSum()
 
+	prc.notifyChanged()
+
 	prc.mu.Unlock()
+
+	if prc.archive != nil {
+		prc.logger.ErrorIfNotNil("error archive bucket:", prc.archive.Append(completed))
+	}
+
+	for _, listener := range prc.listeners {
+		listener.OnShift(completed)
+	}
+
+	prc.publish(completed)
+}
+
+// completedBucket returns the bucket at the current position which is about to be shifted out
+func (prc *RequestCounter) completedBucket(now time.Time) archive.Record {
+	start := time.Unix(0, int64(prc.counts[1]))
+	if prc.counts[1] == 0 || start.After(now) {
+		start = now.Add(-prc.intervalDuration)
+	}
+
+	return archive.Record{
+		Start:    start,
+		Duration: now.Sub(start),
+		Count:    prc.counts[int(prc.counts[0])+2],
+	}
 }
 
 func (prc *RequestCounter) runShift() {
@@ -195,8 +387,27 @@ func (prc *RequestCounter) runShift() {
 	}
 }
 
+// persist flushes storages, every flush is recorded as a child span of the persist span
 func (prc *RequestCounter) persist() {
-	prc.logger.ErrorIfNotNil("error flush mmaped file:", prc.storage.Flush())
+	span := prc.tracer.StartSpan("persist", nil)
+	defer span.Finish()
+
+	prc.logger.ErrorIfNotNil("error flush mmaped file:", prc.flush(span, "counts", prc.storage))
+
+	if len(prc.valueNames) > 0 {
+		prc.logger.ErrorIfNotNil("error flush mmaped values file:", prc.flush(span, "values", prc.valuesStorage))
+	}
+}
+
+func (prc *RequestCounter) flush(parent *tracing.Span, name string, st IStorage) error {
+	span := prc.tracer.StartSpan("flush "+name, parent)
+	defer span.Finish()
+
+	err := st.Flush()
+	if err != nil {
+		span.SetTag("error", err.Error())
+	}
+	return err
 }
 
 func (prc *RequestCounter) runPersist() {
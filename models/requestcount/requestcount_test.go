@@ -1,6 +1,8 @@
 package requestcount
 
 import (
+	"errors"
+	"math"
 	"testing"
 	"time"
 
@@ -161,3 +163,595 @@ func (suite *RequestCounterSuite) Test_Restart_ClearSome(c *C) {
 	c.Assert(counter.counts, DeepEquals, []uint64{3, 0, 0, 0, 1, 1, 0})
 	c.Assert(counter.prevCountsSum, Equals, uint64(2))
 }
+
+func (suite *RequestCounterSuite) Test_AddEvents(c *C) {
+	devnull := log.NewDevNullLogger()
+	ctx := log.SetLoggerToContext(context.Background(), devnull)
+	fakeNow := time.Unix(100, 0)
+	counter := &RequestCounter{
+		counts:           make([]uint64, 7),
+		intervalCount:    5,
+		intervalDuration: time.Second,
+		futureTolerance:  time.Second,
+		logger:           devnull,
+		now: func() time.Time {
+			return fakeNow
+		},
+		storage: storage.NewInmemoryStorage(),
+	}
+
+	counter.shift(time.Unix(98, 0))
+	counter.shift(time.Unix(99, 0))
+	counter.shift(time.Unix(100, 0))
+
+	result, err := counter.AddEvents(ctx, []Event{
+		{Timestamp: time.Unix(100, 500), Count: 1}, // current bucket
+		{Timestamp: time.Unix(101, 0), Count: 2},   // current bucket within tolerance
+		{Timestamp: time.Unix(99, 500), Count: 3},  // previous bucket
+		{Timestamp: time.Unix(96, 0), Count: 4},    // 4 buckets back
+		{Timestamp: time.Unix(95, 0), Count: 5},    // older than the ring
+		{Timestamp: time.Unix(102, 0), Count: 6},   // too far in the future
+	})
+	c.Assert(err, IsNil)
+
+	c.Assert(result.Accepted, Equals, 4)
+	c.Assert(result.Dropped, Equals, 2)
+	c.Assert(result.Count, Equals, uint64(10))
+	c.Assert(counter.counts, DeepEquals, []uint64{3, uint64(fakeNow.UnixNano()), 0, 0, 3, 3, 4})
+
+	c.Assert(counter.Get(ctx).Count, Equals, uint64(11))
+
+	// counts saturate instead of wrapping
+	result, err = counter.AddEvents(ctx, []Event{
+		{Timestamp: time.Unix(100, 0), Count: math.MaxUint64},
+		{Timestamp: time.Unix(99, 0), Count: math.MaxUint64},
+		{Timestamp: time.Unix(99, 0), Count: 1},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(result.Count, Equals, uint64(math.MaxUint64))
+	c.Assert(counter.counts[4:], DeepEquals, []uint64{math.MaxUint64, math.MaxUint64, 4})
+	c.Assert(counter.Get(ctx).Count, Equals, uint64(math.MaxUint64))
+
+	counter.closed = true
+	_, err = counter.AddEvents(ctx, []Event{{Timestamp: time.Unix(100, 0), Count: 1}})
+	c.Assert(err, Equals, ErrClosed)
+}
+
+func (suite *RequestCounterSuite) Test_Weighted(c *C) {
+	devnull := log.NewDevNullLogger()
+	ctx := log.SetLoggerToContext(context.Background(), devnull)
+	fakeNow := time.Unix(0, 0)
+	counter := &RequestCounter{
+		counts:           make([]uint64, 5),
+		intervalCount:    3,
+		intervalDuration: 1,
+		weighted:         make(map[string]aggregateRing),
+		maxWeightedKeys:  1,
+		logger:           devnull,
+		now: func() time.Time {
+			return fakeNow
+		},
+		storage: storage.NewInmemoryStorage(),
+	}
+
+	result, err := counter.Add(ctx, "bytes", 10)
+	c.Assert(err, IsNil)
+	c.Assert(*result, Equals, WeightedCount{Key: "bytes", Count: 1, Sum: 10})
+
+	counter.shift(fakeNow)
+	result, err = counter.Add(ctx, "bytes", math.MaxUint64-5)
+	c.Assert(err, IsNil)
+	c.Assert(*result, Equals, WeightedCount{Key: "bytes", Count: 2, Sum: math.MaxUint64, Saturated: true})
+
+	_, err = counter.Add(ctx, "cost", 1)
+	c.Assert(err, Equals, ErrTooManyKeys)
+
+	counter.shift(fakeNow)
+	counter.shift(fakeNow)
+	result, err = counter.Weighted(ctx, "bytes")
+	c.Assert(err, IsNil)
+	c.Assert(*result, Equals, WeightedCount{Key: "bytes", Count: 1, Sum: math.MaxUint64 - 5})
+
+	// counter without increments during the last time period is removed
+	counter.shift(fakeNow)
+	_, err = counter.Weighted(ctx, "bytes")
+	c.Assert(err, Equals, ErrUnknownKey)
+}
+
+type sharedStorage struct {
+	data []uint64
+}
+
+func (s *sharedStorage) Open(filename string, length int) ([]uint64, error) {
+	if len(s.data) != length {
+		s.data = make([]uint64, length)
+	}
+	return s.data, nil
+}
+
+func (s *sharedStorage) Close() error {
+	return nil
+}
+
+func (s *sharedStorage) Flush() error {
+	return nil
+}
+
+func (suite *RequestCounterSuite) Test_Values(c *C) {
+	devnull := log.NewDevNullLogger()
+	ctx := log.SetLoggerToContext(context.Background(), devnull)
+	fakeNow := time.Unix(0, 0)
+	valuesStorage := &sharedStorage{}
+	newCounter := func(names ...string) *RequestCounter {
+		return &RequestCounter{
+			counts:           make([]uint64, 5),
+			intervalCount:    3,
+			intervalDuration: time.Second,
+			valueNames:       names,
+			logger:           devnull,
+			now: func() time.Time {
+				return fakeNow
+			},
+			storage:       storage.NewInmemoryStorage(),
+			valuesStorage: valuesStorage,
+		}
+	}
+
+	counter := newCounter("latency", "size")
+	c.Assert(counter.openValues(), IsNil)
+
+	for _, v := range []uint64{10, 30} {
+		_, err := counter.Observe(ctx, "latency", v)
+		c.Assert(err, IsNil)
+	}
+	counter.shift(fakeNow)
+	stats, err := counter.Observe(ctx, "latency", 50)
+	c.Assert(err, IsNil)
+	c.Assert(*stats, Equals, ValueStats{Name: "latency", Window: "3s", Count: 3, Sum: 90, Min: 10, Max: 50, Mean: 30})
+
+	stats, err = counter.Values(ctx, "latency", time.Second)
+	c.Assert(err, IsNil)
+	c.Assert(*stats, Equals, ValueStats{Name: "latency", Window: "1s", Count: 1, Sum: 50, Min: 50, Max: 50, Mean: 50})
+
+	stats, err = counter.Values(ctx, "size", 0)
+	c.Assert(err, IsNil)
+	c.Assert(*stats, Equals, ValueStats{Name: "size", Window: "3s"})
+
+	_, err = counter.Observe(ctx, "unknown", 1)
+	c.Assert(err, Equals, ErrUnknownKey)
+
+	// the same layout keeps stored values
+	counter = newCounter("latency", "size")
+	counter.counts[0] = 1
+	c.Assert(counter.openValues(), IsNil)
+	stats, err = counter.Values(ctx, "latency", 0)
+	c.Assert(err, IsNil)
+	c.Assert(stats.Count, Equals, uint64(3))
+
+	// changed layout resets stored values
+	counter = newCounter("size", "latency")
+	counter.counts[0] = 1
+	c.Assert(counter.openValues(), IsNil)
+	stats, err = counter.Values(ctx, "latency", 0)
+	c.Assert(err, IsNil)
+	c.Assert(stats.Count, Equals, uint64(0))
+}
+
+func (suite *RequestCounterSuite) Test_HistogramBins(c *C) {
+	prev := -1
+	for _, us := range []uint64{0, 1, 7, 8, 9, 15, 16, 17, 1000, 1 << 20, 1<<32 - 1, 1 << 40} {
+		bin := histogramBin(us)
+		c.Assert(bin >= prev, Equals, true, Commentf("us: %d", us))
+		c.Assert(bin < histogramBins, Equals, true, Commentf("us: %d", us))
+		prev = bin
+
+		if us < 1<<histogramMaxExp {
+			lower, upper := histogramBinBounds(bin)
+			c.Assert(lower <= us && us < upper, Equals, true, Commentf("us: %d", us))
+		}
+	}
+}
+
+func (suite *RequestCounterSuite) Test_Latency(c *C) {
+	devnull := log.NewDevNullLogger()
+	ctx := log.SetLoggerToContext(context.Background(), devnull)
+	fakeNow := time.Unix(0, 0)
+	counter := &RequestCounter{
+		counts:           make([]uint64, 5),
+		intervalCount:    3,
+		intervalDuration: time.Second,
+		histograms:       make(map[string]histogramRing),
+		logger:           devnull,
+		now: func() time.Time {
+			return fakeNow
+		},
+		storage: storage.NewInmemoryStorage(),
+	}
+
+	for i := 0; i < 99; i++ {
+		counter.ObserveLatency("Get", time.Millisecond)
+	}
+	counter.shift(fakeNow)
+	counter.ObserveLatency("Get", time.Second)
+
+	stats, err := counter.Latency(ctx, "Get", 0)
+	c.Assert(err, IsNil)
+	c.Assert(stats.Count, Equals, uint64(100))
+	c.Assert(stats.P50 > 0.9 && stats.P50 < 1.1, Equals, true, Commentf("p50: %f", stats.P50))
+	c.Assert(stats.P99 > 0.9 && stats.P99 < 1.1, Equals, true, Commentf("p99: %f", stats.P99))
+
+	stats, err = counter.Latency(ctx, "Get", time.Second)
+	c.Assert(err, IsNil)
+	c.Assert(stats.Count, Equals, uint64(1))
+	c.Assert(stats.P50 > 900 && stats.P50 < 1100, Equals, true, Commentf("p50: %f", stats.P50))
+
+	_, err = counter.Latency(ctx, "Post", 0)
+	c.Assert(err, Equals, ErrUnknownKey)
+}
+
+func (suite *RequestCounterSuite) Test_Responses(c *C) {
+	devnull := log.NewDevNullLogger()
+	ctx := log.SetLoggerToContext(context.Background(), devnull)
+	fakeNow := time.Unix(0, 0)
+	counter := &RequestCounter{
+		counts:           make([]uint64, 5),
+		intervalCount:    3,
+		intervalDuration: time.Second,
+		responses:        newSeriesIndex(3, 0),
+		logger:           devnull,
+		now: func() time.Time {
+			return fakeNow
+		},
+		storage: storage.NewInmemoryStorage(),
+	}
+
+	counter.CountResponse("/requestcount", "GET", 200)
+	counter.CountResponse("/requestcount", "GET", 503)
+	counter.shift(fakeNow)
+	counter.CountResponse("/requestcount", "GET", 500)
+	counter.CountResponse("/requestcount", "GET", 200)
+	counter.CountResponse("/requestcount/events", "POST", 400)
+
+	stats, err := counter.Responses(ctx, ResponseFilter{Status: "5xx", Route: "/requestcount"}, 0)
+	c.Assert(err, IsNil)
+	c.Assert(*stats, Equals, ResponseStats{Status: "5xx", Route: "/requestcount", Window: "3s", Count: 2, Total: 4, ErrorRate: 0.5})
+
+	stats, err = counter.Responses(ctx, ResponseFilter{Status: "5", Route: "/requestcount"}, time.Second)
+	c.Assert(err, IsNil)
+	c.Assert(*stats, Equals, ResponseStats{Status: "5xx", Route: "/requestcount", Window: "1s", Count: 1, Total: 2, ErrorRate: 0.5})
+
+	stats, err = counter.Responses(ctx, ResponseFilter{Method: "POST"}, 0)
+	c.Assert(err, IsNil)
+	c.Assert(*stats, Equals, ResponseStats{Method: "POST", Window: "3s", Count: 1, Total: 1})
+
+	_, err = counter.Responses(ctx, ResponseFilter{Status: "6xx"}, 0)
+	c.Assert(err, Equals, ErrInvalidStatusClass)
+}
+
+func (suite *RequestCounterSuite) Test_Series(c *C) {
+	devnull := log.NewDevNullLogger()
+	ctx := log.SetLoggerToContext(context.Background(), devnull)
+	fakeNow := time.Unix(0, 0)
+	counter := &RequestCounter{
+		counts:           make([]uint64, 5),
+		intervalCount:    3,
+		intervalDuration: time.Second,
+		labeled:          newSeriesIndex(3, 3),
+		logger:           devnull,
+		now: func() time.Time {
+			return fakeNow
+		},
+		storage: storage.NewInmemoryStorage(),
+	}
+
+	c.Assert(counter.AddSeries(ctx, "api", Labels{"tenant": "a", "region": "eu"}, 1), IsNil)
+	c.Assert(counter.AddSeries(ctx, "api", Labels{"tenant": "b", "region": "eu"}, 2), IsNil)
+	c.Assert(counter.AddSeries(ctx, "api", Labels{"tenant": "a", "region": "us"}, 4), IsNil)
+	c.Assert(counter.AddSeries(ctx, "api", Labels{"tenant": "a", "region": "eu"}, 8), IsNil)
+	c.Assert(counter.AddSeries(ctx, "api", Labels{"tenant": "c", "region": "eu"}, 1), Equals, ErrTooManySeries)
+	c.Assert(counter.AddSeries(ctx, "api", Labels{"ten=ant": "c"}, 1), Equals, ErrInvalidLabels)
+	c.Assert(counter.AddSeries(ctx, ResponsesSeries, nil, 1), Equals, ErrReservedName)
+
+	result, err := counter.QuerySeries(ctx, "api", Labels{"region": "eu"}, []string{"tenant"}, 0)
+	c.Assert(err, IsNil)
+	c.Assert(result, DeepEquals, &SeriesQueryResult{
+		Name:   "api",
+		Window: "3s",
+		Total:  11,
+		Groups: []SeriesGroup{
+			{Labels: Labels{"tenant": "a"}, Count: 9},
+			{Labels: Labels{"tenant": "b"}, Count: 2},
+		},
+	})
+
+	result, err = counter.QuerySeries(ctx, "api", nil, []string{"region"}, 0)
+	c.Assert(err, IsNil)
+	c.Assert(result.Total, Equals, uint64(15))
+	c.Assert(result.Groups, DeepEquals, []SeriesGroup{
+		{Labels: Labels{"region": "eu"}, Count: 11},
+		{Labels: Labels{"region": "us"}, Count: 4},
+	})
+
+	// series without increments during the last time period are removed
+	counter.shift(fakeNow)
+	c.Assert(counter.AddSeries(ctx, "api", Labels{"tenant": "a", "region": "us"}, 1), IsNil)
+	counter.shift(fakeNow)
+	counter.shift(fakeNow)
+	c.Assert(counter.labeled.series, HasLen, 1)
+	c.Assert(counter.labeled.postings, HasLen, 3)
+	c.Assert(counter.AddSeries(ctx, "api", Labels{"tenant": "c", "region": "eu"}, 1), IsNil)
+
+	result, err = counter.QuerySeries(ctx, "api", Labels{"tenant": "a"}, nil, 0)
+	c.Assert(err, IsNil)
+	c.Assert(result.Total, Equals, uint64(1))
+
+	// series of all names are queried without name
+	c.Assert(counter.AddSeries(ctx, "web", Labels{"tenant": "c"}, 4), IsNil)
+	result, err = counter.QuerySeries(ctx, "", Labels{"tenant": "c"}, []string{"region"}, 0)
+	c.Assert(err, IsNil)
+	c.Assert(result.Total, Equals, uint64(5))
+	c.Assert(result.Groups, DeepEquals, []SeriesGroup{
+		{Labels: Labels{"region": ""}, Count: 4},
+		{Labels: Labels{"region": "eu"}, Count: 1},
+	})
+
+	result, err = counter.QuerySeries(ctx, "", nil, nil, 0)
+	c.Assert(err, IsNil)
+	c.Assert(result.Total, Equals, uint64(6))
+}
+
+func (suite *RequestCounterSuite) Test_Eval(c *C) {
+	devnull := log.NewDevNullLogger()
+	ctx := log.SetLoggerToContext(context.Background(), devnull)
+	fakeNow := time.Unix(0, 0)
+	counter := &RequestCounter{
+		counts:           make([]uint64, 5),
+		intervalCount:    3,
+		intervalDuration: time.Second,
+		weighted:         make(map[string]aggregateRing),
+		maxWeightedKeys:  1,
+		responses:        newSeriesIndex(3, 0),
+		labeled:          newSeriesIndex(3, 0),
+		logger:           devnull,
+		now: func() time.Time {
+			return fakeNow
+		},
+		storage: storage.NewInmemoryStorage(),
+	}
+
+	for i := 0; i < 3; i++ {
+		counter.Get(ctx)
+	}
+	counter.CountResponse("/requestcount", "GET", 200)
+	counter.CountResponse("/requestcount", "GET", 500)
+	counter.shift(fakeNow)
+	counter.Get(ctx)
+	_, err := counter.Add(ctx, "bytes", 300)
+	c.Assert(err, IsNil)
+	c.Assert(counter.AddSeries(ctx, "api", Labels{"tenant": "a"}, 5), IsNil)
+
+	for _, tc := range []struct {
+		query string
+		value float64
+	}{
+		{"requests", 4},
+		{"requests[1s]", 1},
+		{"rate(requests[3s])", 4.0 / 3},
+		{`responses{status="5xx"} / responses`, 0.5},
+		{"bytes / requests", 75},
+		{`api{tenant="a"} + api{tenant="b"}`, 5},
+		{"unknown", 0},
+	} {
+		result, err := counter.Eval(ctx, tc.query)
+		c.Assert(err, IsNil, Commentf("query: %s", tc.query))
+		c.Assert(result.Value, Equals, tc.value, Commentf("query: %s", tc.query))
+	}
+
+	for _, tc := range []struct {
+		query string
+		err   error
+	}{
+		{`requests{route="/"}`, ErrCounterHasNoLabels},
+		{`rate(bytes{key="a"}[1s])`, ErrCounterHasNoLabels},
+	} {
+		_, err := counter.Eval(ctx, tc.query)
+		c.Assert(errors.Is(err, tc.err), Equals, true, Commentf("query: %s, error: %v", tc.query, err))
+	}
+}
+
+func (suite *RequestCounterSuite) Test_Anomalies(c *C) {
+	devnull := log.NewDevNullLogger()
+	ctx := log.SetLoggerToContext(context.Background(), devnull)
+	fakeNow := time.Unix(100, 0)
+
+	for _, method := range []string{AnomalyZScore, AnomalyMAD} {
+		counter := &RequestCounter{
+			counts:           make([]uint64, 12),
+			intervalCount:    10,
+			intervalDuration: time.Second,
+			responses:        newSeriesIndex(10, 0),
+			labeled:          newSeriesIndex(10, 0),
+			logger:           devnull,
+			anomalyConfig:    &AnomalyConfig{Method: method, Window: 5, Threshold: 3, MaxEvents: 2},
+			now: func() time.Time {
+				return fakeNow
+			},
+			storage: storage.NewInmemoryStorage(),
+		}
+		c.Assert(counter.openAnomalies(0), IsNil)
+
+		for _, n := range []int{100, 104, 96, 101, 99, 2, 100, 97, 103, 98, 102, 300} {
+			for i := 0; i < n; i++ {
+				counter.Get(ctx)
+			}
+			fakeNow = fakeNow.Add(time.Second)
+			counter.shift(fakeNow)
+		}
+
+		anomalies, err := counter.Anomalies(ctx)
+		c.Assert(err, IsNil)
+		c.Assert(anomalies.Method, Equals, method)
+		c.Assert(anomalies.Events, HasLen, 2, Commentf("method: %s", method))
+		c.Assert(anomalies.Events[0].Kind, Equals, AnomalyDrop)
+		c.Assert(anomalies.Events[0].Count, Equals, uint64(2))
+		c.Assert(anomalies.Events[1].Kind, Equals, AnomalySpike)
+		c.Assert(anomalies.Events[1].Count, Equals, uint64(300))
+		c.Assert(anomalies.Score > 3, Equals, true)
+		c.Assert(*counter.Get(ctx).AnomalyScore, Equals, anomalies.Score)
+	}
+
+	counter := &RequestCounter{}
+	_, err := counter.Anomalies(ctx)
+	c.Assert(err, Equals, ErrAnomalyDisabled)
+
+	_, err = newAnomalyDetector(&AnomalyConfig{Method: "ewma"})
+	c.Assert(err, Equals, ErrUnknownAnomalyMethod)
+
+	_, err = newAnomalyDetector(&AnomalyConfig{Method: AnomalyZScore, Window: -1})
+	c.Assert(err, Equals, ErrInvalidAnomalyWindow)
+}
+
+// restoredStorage returns counts restored after restart
+type restoredStorage struct {
+	counts []uint64
+}
+
+func (st *restoredStorage) Open(filename string, length int) ([]uint64, error) {
+	return st.counts, nil
+}
+
+func (st *restoredStorage) Close() error {
+	return nil
+}
+
+func (st *restoredStorage) Flush() error {
+	return nil
+}
+
+func (suite *RequestCounterSuite) Test_AnomaliesAfterDowntime(c *C) {
+	for _, tc := range []struct {
+		downtime time.Duration
+		baseline []float64
+	}{
+		{2 * time.Second, []float64{20}},
+		{10 * time.Second, nil},
+	} {
+		fakeNow := time.Unix(100, 0).Add(tc.downtime)
+		counter := &RequestCounter{
+			done:             make(chan struct{}),
+			intervalCount:    5,
+			intervalDuration: time.Second,
+			logger:           log.NewDevNullLogger(),
+			anomalyConfig:    &AnomalyConfig{Method: AnomalyZScore, Window: 5, Threshold: 3},
+			now: func() time.Time {
+				return fakeNow
+			},
+			// the current interval 2 is started at 100s, intervals 3, 4 and 0 are outdated after 2s
+			storage: &restoredStorage{counts: []uint64{2, uint64(time.Unix(100, 0).UnixNano()), 10, 20, 30, 40, 50}},
+		}
+		c.Assert(counter.Run(), IsNil)
+
+		counter.mu.Lock()
+		baseline := counter.anomalies.history[:counter.anomalies.filled]
+		counter.mu.Unlock()
+		c.Assert(baseline, DeepEquals, append([]float64{}, tc.baseline...), Commentf("downtime: %s", tc.downtime))
+
+		c.Assert(counter.Close(), IsNil)
+	}
+}
+
+func (suite *RequestCounterSuite) Test_Subscribe(c *C) {
+	devnull := log.NewDevNullLogger()
+	ctx := log.SetLoggerToContext(context.Background(), devnull)
+	fakeNow := time.Unix(100, 0)
+	counter := &RequestCounter{
+		counts:           make([]uint64, 5),
+		intervalCount:    3,
+		intervalDuration: time.Second,
+		responses:        newSeriesIndex(3, 0),
+		labeled:          newSeriesIndex(3, 0),
+		maxSubscribers:   1,
+		logger:           devnull,
+		now: func() time.Time {
+			return fakeNow
+		},
+		storage: storage.NewInmemoryStorage(),
+	}
+
+	shifts, unsubscribe, err := counter.Subscribe()
+	c.Assert(err, IsNil)
+
+	_, _, err = counter.Subscribe()
+	c.Assert(err, Equals, ErrTooManySubscribers)
+
+	counter.Get(ctx)
+	counter.Get(ctx)
+	c.Assert(counter.Peek().Count, Equals, uint64(2))
+	c.Assert(counter.Peek().Count, Equals, uint64(2))
+
+	fakeNow = fakeNow.Add(time.Second)
+	counter.shift(fakeNow)
+	// the second interval is dropped as the first one is not received yet
+	counter.shift(fakeNow.Add(time.Second))
+
+	rec := <-shifts
+	c.Assert(rec.Count, Equals, uint64(2))
+	c.Assert(rec.Start.Equal(time.Unix(100, 0)), Equals, true)
+
+	unsubscribe()
+	_, ok := <-shifts
+	c.Assert(ok, Equals, false)
+
+	_, _, err = counter.Subscribe()
+	c.Assert(err, IsNil)
+}
+
+func (suite *RequestCounterSuite) Test_Wait(c *C) {
+	devnull := log.NewDevNullLogger()
+	ctx := log.SetLoggerToContext(context.Background(), devnull)
+	fakeNow := time.Unix(100, 0)
+	counter := &RequestCounter{
+		counts:           make([]uint64, 5),
+		intervalCount:    3,
+		intervalDuration: time.Second,
+		responses:        newSeriesIndex(3, 0),
+		labeled:          newSeriesIndex(3, 0),
+		done:             make(chan struct{}),
+		logger:           devnull,
+		now: func() time.Time {
+			return fakeNow
+		},
+		storage: storage.NewInmemoryStorage(),
+	}
+
+	initial := counter.Peek()
+	c.Assert(initial.ETag, Matches, `"[0-9a-f]+-[0-9a-f]+"`)
+	c.Assert(counter.Peek().ETag, Equals, initial.ETag)
+
+	// unchanged state times out
+	count := counter.Wait(ctx, initial.ETag, 10*time.Millisecond)
+	c.Assert(count.ETag, Equals, initial.ETag)
+
+	// changed state returns immediately
+	counted := counter.Get(ctx)
+	c.Assert(counted.ETag, Not(Equals), initial.ETag)
+	count = counter.Wait(ctx, initial.ETag, time.Hour)
+	c.Assert(count.Count, Equals, uint64(1))
+	c.Assert(count.ETag, Equals, counted.ETag)
+
+	done := make(chan *RequestCount)
+	go func() {
+		done <- counter.Wait(ctx, counted.ETag, time.Hour)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	counter.shift(fakeNow.Add(time.Second))
+
+	select {
+	case count = <-done:
+		c.Assert(count.Count, Equals, uint64(1))
+		c.Assert(count.ETag, Not(Equals), counted.ETag)
+	case <-time.After(5 * time.Second):
+		c.Fatal("waiter is not woken up by shift")
+	}
+}
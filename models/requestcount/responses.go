@@ -0,0 +1,160 @@
+package requestcount
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/THE108/requestcounter/utils/metrics"
+
+	"golang.org/x/net/context"
+)
+
+var ErrInvalidStatusClass = errors.New("invalid status class, expected one of 1xx, 2xx, 3xx, 4xx, 5xx")
+
+type ResponseFilter struct {
+	Route  string
+	Method string
+	Status string
+}
+
+type ResponseStats struct {
+	Route     string  `json:"route,omitempty"`
+	Method    string  `json:"method,omitempty"`
+	Status    string  `json:"status,omitempty"`
+	Window    string  `json:"window"`
+	Count     uint64  `json:"count"`
+	Total     uint64  `json:"total"`
+	ErrorRate float64 `json:"error_rate"`
+}
+
+// counterRing is a ring of counts aligned with the main ring: index i corresponds to counts[i+2]
+type counterRing []uint64
+
+func (r counterRing) add(index int, n uint64) {
+	r[index] = addSaturating(r[index], n)
+}
+
+func (r counterRing) sum(current, count int) uint64 {
+	length := len(r)
+	if count <= 0 || count > length {
+		count = length
+	}
+
+	var sum uint64
+	for i := 0; i < count; i++ {
+		sum = addSaturating(sum, r[(current-i+length)%length])
+	}
+	return sum
+}
+
+// StatusClass returns class of HTTP status code like 2xx or 5xx
+func StatusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "5xx"
+	}
+	return strconv.Itoa(status/100) + "xx"
+}
+
+// NormalizeStatusClass converts status class given as 5xx, 5XX or 5 to 5xx
+func NormalizeStatusClass(class string) (string, error) {
+	switch class {
+	case "":
+		return "", nil
+	case "1", "1xx", "1XX":
+		return "1xx", nil
+	case "2", "2xx", "2XX":
+		return "2xx", nil
+	case "3", "3xx", "3XX":
+		return "3xx", nil
+	case "4", "4xx", "4XX":
+		return "4xx", nil
+	case "5", "5xx", "5XX":
+		return "5xx", nil
+	}
+	return "", ErrInvalidStatusClass
+}
+
+// CountResponse records response of route template with given method and status code
+func (prc *RequestCounter) CountResponse(route, method string, status int) {
+	labels := Labels{
+		"route":  route,
+		"method": method,
+		"status": StatusClass(status),
+	}
+
+	prc.mu.Lock()
+	defer prc.mu.Unlock()
+
+	if prc.closed {
+		return
+	}
+
+	prc.responses.add(int(prc.counts[0]), ResponsesSeries, labels, 1)
+}
+
+// Responses returns count of responses matching filter during window,
+// total count of responses of matched routes and methods and their error (5xx) rate.
+// Empty filter fields match any value. Zero window means the whole time period.
+func (prc *RequestCounter) Responses(ctx context.Context, filter ResponseFilter, window time.Duration) (*ResponseStats, error) {
+	status, err := NormalizeStatusClass(filter.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	matchers := Labels{}
+	if filter.Route != "" {
+		matchers["route"] = filter.Route
+	}
+	if filter.Method != "" {
+		matchers["method"] = filter.Method
+	}
+
+	result, err := prc.QuerySeries(ctx, ResponsesSeries, matchers, []string{"status"}, window)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &ResponseStats{
+		Route:  filter.Route,
+		Method: filter.Method,
+		Status: status,
+		Window: result.Window,
+		Total:  result.Total,
+	}
+
+	var errorsCount uint64
+	for _, group := range result.Groups {
+		if status == "" || group.Labels["status"] == status {
+			stats.Count = addSaturating(stats.Count, group.Count)
+		}
+		if group.Labels["status"] == "5xx" {
+			errorsCount = group.Count
+		}
+	}
+
+	if stats.Total > 0 {
+		stats.ErrorRate = float64(errorsCount) / float64(stats.Total)
+	}
+
+	return stats, nil
+}
+
+func (prc *RequestCounter) writeResponsesMetrics(w *metrics.Writer) {
+	result, err := prc.QuerySeries(context.Background(), ResponsesSeries, nil, []string{"route", "method", "status"}, 0)
+	if err != nil {
+		return
+	}
+
+	sort.Slice(result.Groups, func(i, j int) bool {
+		return result.Groups[i].Labels.key("") < result.Groups[j].Labels.key("")
+	})
+
+	const name = "requestcounter_window_responses"
+	w.Header(name, metrics.Gauge, "Count of responses during the last time period.")
+	for _, group := range result.Groups {
+		w.Sample(name, float64(group.Count),
+			"route", group.Labels["route"], "method", group.Labels["method"], "status", group.Labels["status"])
+	}
+}
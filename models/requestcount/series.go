@@ -0,0 +1,287 @@
+package requestcount
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/THE108/requestcounter/utils/log"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	maxSeriesLabels = 16
+	maxLabelLength  = 128
+	seriesNameLabel = "__name__"
+
+	// ResponsesSeries is a name of series of responses labeled by route, method and status
+	ResponsesSeries = "responses"
+)
+
+var (
+	ErrTooManySeries = errors.New("too many series")
+	ErrInvalidLabels = errors.New("invalid labels")
+	ErrReservedName  = errors.New("series name is reserved")
+)
+
+type Labels map[string]string
+
+// key returns canonical representation of series: name{label1=value1,label2=value2}
+func (labels Labels) key(name string) string {
+	names := make([]string, 0, len(labels))
+	for label := range labels {
+		names = append(names, label)
+	}
+	sort.Strings(names)
+
+	buf := make([]byte, 0, 64)
+	buf = append(buf, name...)
+	buf = append(buf, '{')
+	for i, label := range names {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, label...)
+		buf = append(buf, '=')
+		buf = append(buf, labels[label]...)
+	}
+	buf = append(buf, '}')
+
+	return string(buf)
+}
+
+func validateLabels(name string, labels Labels) error {
+	if name == "" || len(name) > maxLabelLength || len(labels) > maxSeriesLabels {
+		return ErrInvalidLabels
+	}
+
+	for label, value := range labels {
+		if label == "" || label == seriesNameLabel || len(label) > maxLabelLength || len(value) > maxLabelLength {
+			return ErrInvalidLabels
+		}
+		if strings.ContainsAny(label, "{}=,") || strings.ContainsAny(value, "{}=,") {
+			return ErrInvalidLabels
+		}
+	}
+
+	return nil
+}
+
+type labelPair struct {
+	name  string
+	value string
+}
+
+type series struct {
+	name   string
+	labels Labels
+	ring   counterRing
+}
+
+// seriesIndex keeps labeled series and inverted index of label pairs to series
+type seriesIndex struct {
+	intervalCount int
+	maxSeries     int
+	series        map[string]*series
+	postings      map[labelPair]map[string]*series
+}
+
+func newSeriesIndex(intervalCount, maxSeries int) *seriesIndex {
+	return &seriesIndex{
+		intervalCount: intervalCount,
+		maxSeries:     maxSeries,
+		series:        make(map[string]*series),
+		postings:      make(map[labelPair]map[string]*series),
+	}
+}
+
+func (idx *seriesIndex) add(index int, name string, labels Labels, n uint64) error {
+	key := labels.key(name)
+
+	s, ok := idx.series[key]
+	if !ok {
+		if idx.maxSeries > 0 && len(idx.series) >= idx.maxSeries {
+			return ErrTooManySeries
+		}
+
+		s = &series{
+			name:   name,
+			labels: labels,
+			ring:   make(counterRing, idx.intervalCount),
+		}
+		idx.series[key] = s
+
+		idx.forEachPair(s, func(pair labelPair) {
+			keys, ok := idx.postings[pair]
+			if !ok {
+				keys = make(map[string]*series)
+				idx.postings[pair] = keys
+			}
+			keys[key] = s
+		})
+	}
+
+	s.ring.add(index, n)
+
+	return nil
+}
+
+// match returns series with given name having all labels of matchers, empty name matches series of any name
+func (idx *seriesIndex) match(name string, matchers Labels) []*series {
+	var lists []map[string]*series
+	if name != "" {
+		lists = append(lists, idx.postings[labelPair{seriesNameLabel, name}])
+	}
+	for label, value := range matchers {
+		lists = append(lists, idx.postings[labelPair{label, value}])
+	}
+
+	if len(lists) == 0 {
+		lists = append(lists, idx.series)
+	}
+
+	// intersect starting from the shortest postings list
+	sort.Slice(lists, func(i, j int) bool { return len(lists[i]) < len(lists[j]) })
+
+	var result []*series
+	for key, s := range lists[0] {
+		matched := true
+		for _, list := range lists[1:] {
+			if _, ok := list[key]; !ok {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			result = append(result, s)
+		}
+	}
+
+	return result
+}
+
+// clear zeroes interval at index in all series and removes series
+// which have no increments during the last time period
+func (idx *seriesIndex) clear(index int) {
+	for key, s := range idx.series {
+		s.ring[index] = 0
+		if s.ring.sum(0, 0) != 0 {
+			continue
+		}
+
+		delete(idx.series, key)
+		idx.forEachPair(s, func(pair labelPair) {
+			delete(idx.postings[pair], key)
+			if len(idx.postings[pair]) == 0 {
+				delete(idx.postings, pair)
+			}
+		})
+	}
+}
+
+func (idx *seriesIndex) forEachPair(s *series, f func(pair labelPair)) {
+	f(labelPair{seriesNameLabel, s.name})
+	for label, value := range s.labels {
+		f(labelPair{label, value})
+	}
+}
+
+type SeriesGroup struct {
+	Labels Labels `json:"labels"`
+	Count  uint64 `json:"count"`
+}
+
+type SeriesQueryResult struct {
+	Name   string        `json:"name,omitempty"`
+	Window string        `json:"window"`
+	Total  uint64        `json:"total"`
+	Groups []SeriesGroup `json:"groups"`
+}
+
+// AddSeries adds n to the current interval of series name with given labels
+func (prc *RequestCounter) AddSeries(ctx context.Context, name string, labels Labels, n uint64) error {
+	if name == ResponsesSeries {
+		return ErrReservedName
+	}
+
+	if err := validateLabels(name, labels); err != nil {
+		return err
+	}
+
+	prc.mu.Lock()
+	if prc.closed {
+		prc.mu.Unlock()
+		return ErrClosed
+	}
+	err := prc.labeled.add(int(prc.counts[0]), name, labels, n)
+	prc.mu.Unlock()
+
+	log.GetLoggerFromContext(ctx).Debugf("series: %s add: %d", labels.key(name), n)
+
+	return err
+}
+
+// QuerySeries returns window totals of series name matching filter summed by values of sumBy labels.
+// Empty name queries labeled series of all names, zero window means the whole time period.
+func (prc *RequestCounter) QuerySeries(ctx context.Context, name string, filter Labels, sumBy []string, window time.Duration) (*SeriesQueryResult, error) {
+	prc.mu.Lock()
+	defer prc.mu.Unlock()
+
+	if prc.closed {
+		return nil, ErrClosed
+	}
+
+	buckets := prc.windowBuckets(window)
+	result := &SeriesQueryResult{
+		Name:   name,
+		Window: (prc.intervalDuration * time.Duration(buckets)).String(),
+		Groups: []SeriesGroup{},
+	}
+
+	groups := make(map[string]int)
+	for _, s := range prc.seriesIndex(name).match(name, filter) {
+		sum := s.ring.sum(int(prc.counts[0]), buckets)
+		result.Total = addSaturating(result.Total, sum)
+
+		groupLabels := make(Labels, len(sumBy))
+		for _, label := range sumBy {
+			groupLabels[label] = s.labels[label]
+		}
+
+		key := groupLabels.key("")
+		i, ok := groups[key]
+		if !ok {
+			i = len(result.Groups)
+			groups[key] = i
+			result.Groups = append(result.Groups, SeriesGroup{Labels: groupLabels})
+		}
+		result.Groups[i].Count = addSaturating(result.Groups[i].Count, sum)
+	}
+
+	sort.Slice(result.Groups, func(i, j int) bool {
+		if result.Groups[i].Count != result.Groups[j].Count {
+			return result.Groups[i].Count > result.Groups[j].Count
+		}
+		return result.Groups[i].Labels.key("") < result.Groups[j].Labels.key("")
+	})
+
+	return result, nil
+}
+
+func (prc *RequestCounter) clearSeries(index int) {
+	if prc.responses != nil {
+		prc.responses.clear(index)
+	}
+	if prc.labeled != nil {
+		prc.labeled.clear(index)
+	}
+}
+
+func (prc *RequestCounter) seriesIndex(name string) *seriesIndex {
+	if name == ResponsesSeries {
+		return prc.responses
+	}
+	return prc.labeled
+}
@@ -0,0 +1,74 @@
+package requestcount
+
+import (
+	"errors"
+
+	"github.com/THE108/requestcounter/utils/archive"
+)
+
+var ErrTooManySubscribers = errors.New("too many subscribers")
+
+// Peek returns count of requests during the last time period without counting the request itself
+func (prc *RequestCounter) Peek() *RequestCount {
+	prc.mu.Lock()
+	defer prc.mu.Unlock()
+
+	if prc.closed {
+		return nil
+	}
+
+	return prc.peek()
+}
+
+// Subscribe returns a channel receiving every completed interval and a function to unsubscribe.
+// Intervals are dropped for a subscriber which has not received the previous one yet.
+// The channel is closed when the subscriber unsubscribes or the counter is closed.
+func (prc *RequestCounter) Subscribe() (<-chan archive.Record, func(), error) {
+	prc.subMu.Lock()
+	defer prc.subMu.Unlock()
+
+	if prc.subscribers == nil {
+		prc.subscribers = make(map[chan archive.Record]struct{})
+	}
+
+	if prc.maxSubscribers > 0 && len(prc.subscribers) >= prc.maxSubscribers {
+		return nil, nil, ErrTooManySubscribers
+	}
+
+	ch := make(chan archive.Record, 1)
+	prc.subscribers[ch] = struct{}{}
+
+	unsubscribe := func() {
+		prc.subMu.Lock()
+		defer prc.subMu.Unlock()
+
+		if _, ok := prc.subscribers[ch]; ok {
+			delete(prc.subscribers, ch)
+			close(ch)
+		}
+	}
+
+	return ch, unsubscribe, nil
+}
+
+func (prc *RequestCounter) publish(completed archive.Record) {
+	prc.subMu.Lock()
+	defer prc.subMu.Unlock()
+
+	for ch := range prc.subscribers {
+		select {
+		case ch <- completed:
+		default:
+		}
+	}
+}
+
+func (prc *RequestCounter) closeSubscribers() {
+	prc.subMu.Lock()
+	defer prc.subMu.Unlock()
+
+	for ch := range prc.subscribers {
+		delete(prc.subscribers, ch)
+		close(ch)
+	}
+}
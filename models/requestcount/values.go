@@ -0,0 +1,182 @@
+package requestcount
+
+import (
+	"hash/fnv"
+	"time"
+
+	"github.com/THE108/requestcounter/utils/log"
+
+	"golang.org/x/net/context"
+)
+
+// Value counters are persisted in a separate file which starts with a header
+// describing the layout of the data:
+//
+//	[0] magic
+//	[1] layout version
+//	[2] count of intervals
+//	[3] count of value counters
+//	[4] count of uint64 fields in a bucket
+//	[5...5+counters) FNV-1a hash of each value counter name
+//
+// The header is followed by aggregate rings of all value counters in the configured order.
+const (
+	valuesMagic        = 0x52435641 // "RCVA"
+	valuesVersion      = 1
+	valuesHeaderLength = 5
+	valuesFileSuffix   = ".values"
+)
+
+type ValueStats struct {
+	Name   string  `json:"name"`
+	Window string  `json:"window"`
+	Count  uint64  `json:"count"`
+	Sum    uint64  `json:"sum"`
+	Min    uint64  `json:"min"`
+	Max    uint64  `json:"max"`
+	Mean   float64 `json:"mean"`
+}
+
+func (prc *RequestCounter) openValues() error {
+	if len(prc.valueNames) == 0 {
+		return nil
+	}
+
+	headerLength := valuesHeaderLength + len(prc.valueNames)
+	ringLength := prc.intervalCount * aggregateFields
+	length := headerLength + len(prc.valueNames)*ringLength
+
+	data, err := prc.valuesStorage.Open(prc.filename+valuesFileSuffix, length)
+	if err != nil {
+		return err
+	}
+
+	header := prc.valuesHeader()
+	if !equalUint64s(data[:headerLength], header) {
+		if data[0] != 0 {
+			prc.logger.Warning("value counters layout is changed, stored values are reset")
+		}
+
+		for i := range data {
+			data[i] = 0
+		}
+		copy(data, header)
+	}
+
+	prc.values = make(map[string]aggregateRing, len(prc.valueNames))
+	for i, name := range prc.valueNames {
+		offset := headerLength + i*ringLength
+		prc.values[name] = aggregateRing(data[offset : offset+ringLength])
+	}
+
+	return nil
+}
+
+func (prc *RequestCounter) valuesHeader() []uint64 {
+	header := []uint64{
+		valuesMagic,
+		valuesVersion,
+		uint64(prc.intervalCount),
+		uint64(len(prc.valueNames)),
+		aggregateFields,
+	}
+
+	for _, name := range prc.valueNames {
+		h := fnv.New64a()
+		h.Write([]byte(name))
+		header = append(header, h.Sum64())
+	}
+
+	return header
+}
+
+// Observe records value into the current interval of value counter name
+func (prc *RequestCounter) Observe(ctx context.Context, name string, value uint64) (*ValueStats, error) {
+	prc.mu.Lock()
+	if prc.closed {
+		prc.mu.Unlock()
+		return nil, ErrClosed
+	}
+
+	ring, ok := prc.values[name]
+	if !ok {
+		prc.mu.Unlock()
+		return nil, ErrUnknownKey
+	}
+
+	index := int(prc.counts[0])
+	ring.observe(index, value)
+	stats := prc.valueStats(name, ring, 0)
+	prc.mu.Unlock()
+
+	log.GetLoggerFromContext(ctx).Debugf("value counter: %s value: %d", name, value)
+
+	return stats, nil
+}
+
+// Values returns aggregated values of value counter name observed during window.
+// Zero window means the whole time period.
+func (prc *RequestCounter) Values(ctx context.Context, name string, window time.Duration) (*ValueStats, error) {
+	prc.mu.Lock()
+	defer prc.mu.Unlock()
+
+	if prc.closed {
+		return nil, ErrClosed
+	}
+
+	ring, ok := prc.values[name]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	return prc.valueStats(name, ring, window), nil
+}
+
+func (prc *RequestCounter) valueStats(name string, ring aggregateRing, window time.Duration) *ValueStats {
+	buckets := prc.windowBuckets(window)
+	agg := ring.merge(int(prc.counts[0]), buckets)
+
+	return &ValueStats{
+		Name:   name,
+		Window: (prc.intervalDuration * time.Duration(buckets)).String(),
+		Count:  agg.Count,
+		Sum:    agg.Sum,
+		Min:    agg.Min,
+		Max:    agg.Max,
+		Mean:   agg.Mean(),
+	}
+}
+
+// windowBuckets returns count of intervals covering window
+func (prc *RequestCounter) windowBuckets(window time.Duration) int {
+	if window <= 0 {
+		return prc.intervalCount
+	}
+
+	buckets := int((window + prc.intervalDuration - 1) / prc.intervalDuration)
+	if buckets > prc.intervalCount {
+		buckets = prc.intervalCount
+	}
+
+	return buckets
+}
+
+func (prc *RequestCounter) clearValues(index int) {
+	for _, ring := range prc.values {
+		ring.clear(index)
+	}
+}
+
+func equalUint64s(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
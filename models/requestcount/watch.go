@@ -0,0 +1,87 @@
+package requestcount
+
+import (
+	"strconv"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// GetETag returns entity tag of the ring state the count is read from
+func (rc *RequestCount) GetETag() string {
+	if rc == nil {
+		return ""
+	}
+	return rc.ETag
+}
+
+// etag returns quoted sequence number of the ring change, must be called under lock.
+// The epoch distinguishes tags of the previous runs which sequence started from zero too.
+func (prc *RequestCounter) etag() string {
+	return `"` + strconv.FormatUint(prc.epoch, 16) + "-" + strconv.FormatUint(prc.changeSeq, 16) + `"`
+}
+
+// peek returns the current count, must be called under lock
+func (prc *RequestCounter) peek() *RequestCount {
+	return &RequestCount{
+		Count:        addSaturating(prc.counts[int(prc.counts[0])+2], prc.prevCountsSum),
+		AnomalyScore: prc.anomalyScore(),
+		ETag:         prc.etag(),
+	}
+}
+
+// changes returns a channel closed on the next change of the ring, must be called under lock
+func (prc *RequestCounter) changes() <-chan struct{} {
+	if prc.changed == nil {
+		prc.changed = make(chan struct{})
+	}
+	return prc.changed
+}
+
+// notifyChanged changes ETag and wakes up waiters of the ring change, must be called under lock
+func (prc *RequestCounter) notifyChanged() {
+	prc.changeSeq++
+	if prc.changed != nil {
+		close(prc.changed)
+		prc.changed = nil
+	}
+}
+
+// Wait blocks until ETag of the ring state differs from etag, timeout expires or ctx is done
+// and returns the current count without counting the request itself.
+// Empty etag means the state at the moment of the call.
+func (prc *RequestCounter) Wait(ctx context.Context, etag string, timeout time.Duration) *RequestCount {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		prc.mu.Lock()
+		if prc.closed {
+			prc.mu.Unlock()
+			return nil
+		}
+
+		count := prc.peek()
+		if etag == "" {
+			etag = count.ETag
+		}
+
+		if count.ETag != etag {
+			prc.mu.Unlock()
+			return count
+		}
+
+		changed := prc.changes()
+		prc.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return prc.Peek()
+		case <-ctx.Done():
+			return prc.Peek()
+		case <-prc.done:
+			return prc.Peek()
+		}
+	}
+}
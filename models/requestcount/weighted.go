@@ -0,0 +1,89 @@
+package requestcount
+
+import (
+	"errors"
+	"math"
+
+	"github.com/THE108/requestcounter/utils/log"
+
+	"golang.org/x/net/context"
+)
+
+var (
+	ErrUnknownKey  = errors.New("unknown key")
+	ErrTooManyKeys = errors.New("too many keys")
+)
+
+type WeightedCount struct {
+	Key       string `json:"key"`
+	Count     uint64 `json:"count"`
+	Sum       uint64 `json:"sum"`
+	Saturated bool   `json:"saturated,omitempty"`
+}
+
+func newWeightedCount(key string, agg Aggregate) *WeightedCount {
+	return &WeightedCount{
+		Key:       key,
+		Count:     agg.Count,
+		Sum:       agg.Sum,
+		Saturated: agg.Count == math.MaxUint64 || agg.Sum == math.MaxUint64,
+	}
+}
+
+// Add adds n to the sum of key in the current interval
+func (prc *RequestCounter) Add(ctx context.Context, key string, n uint64) (*WeightedCount, error) {
+	prc.mu.Lock()
+	if prc.closed {
+		prc.mu.Unlock()
+		return nil, ErrClosed
+	}
+
+	ring, ok := prc.weighted[key]
+	if !ok {
+		if len(prc.weighted) >= prc.maxWeightedKeys {
+			prc.mu.Unlock()
+			return nil, ErrTooManyKeys
+		}
+
+		ring = newAggregateRing(prc.intervalCount)
+		prc.weighted[key] = ring
+	}
+
+	index := int(prc.counts[0])
+	ring.observe(index, n)
+
+	result := newWeightedCount(key, ring.merge(index, prc.intervalCount))
+	prc.mu.Unlock()
+
+	log.GetLoggerFromContext(ctx).Debugf("key: %s count: %d sum: %d", key, result.Count, result.Sum)
+
+	return result, nil
+}
+
+// Weighted returns count of increments and sum of amounts of key during the last time period
+func (prc *RequestCounter) Weighted(ctx context.Context, key string) (*WeightedCount, error) {
+	prc.mu.Lock()
+	defer prc.mu.Unlock()
+
+	if prc.closed {
+		return nil, ErrClosed
+	}
+
+	ring, ok := prc.weighted[key]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	return newWeightedCount(key, ring.merge(int(prc.counts[0]), prc.intervalCount)), nil
+}
+
+// clearWeighted zeroes interval at index in all weighted counters
+// and removes counters which have no increments during the last time period
+func (prc *RequestCounter) clearWeighted(index int) {
+	for key, ring := range prc.weighted {
+		ring.clear(index)
+		if ring.empty() {
+			delete(prc.weighted, key)
+		}
+	}
+}
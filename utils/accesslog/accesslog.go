@@ -0,0 +1,176 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Formats of access log
+const (
+	FormatCombined = "combined"
+	FormatJSON     = "json"
+)
+
+// Entry describes served request
+type Entry struct {
+	Time       time.Time `json:"time"`
+	RemoteAddr string    `json:"remote_addr"`
+	Method     string    `json:"method"`
+	URI        string    `json:"uri"`
+	Proto      string    `json:"proto"`
+	Status     int       `json:"status"`
+	Bytes      int       `json:"bytes"`
+	LatencyMs  float64   `json:"latency_ms"`
+	Referer    string    `json:"referer,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	TraceID    string    `json:"trace_id,omitempty"`
+	SpanID     string    `json:"span_id,omitempty"`
+}
+
+// NewEntry returns entry of request with response status and size which is served since start
+func NewEntry(req *http.Request, status, size int, start time.Time) *Entry {
+	remoteAddr := req.RemoteAddr
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		remoteAddr = host
+	}
+
+	return &Entry{
+		Time:       start,
+		RemoteAddr: remoteAddr,
+		Method:     req.Method,
+		URI:        req.RequestURI,
+		Proto:      req.Proto,
+		Status:     status,
+		Bytes:      size,
+		LatencyMs:  float64(time.Since(start)) / float64(time.Millisecond),
+		Referer:    req.Referer(),
+		UserAgent:  req.UserAgent(),
+	}
+}
+
+type Config struct {
+	Format string
+	// SampleRate is a fraction of logged responses, responses with status 5xx are always logged
+	SampleRate float64
+	Writer     io.Writer
+}
+
+// Logger writes access log entries, one line per entry
+type Logger struct {
+	mu         sync.Mutex
+	format     func(buf *bytes.Buffer, e *Entry)
+	sampleRate float64
+	w          io.Writer
+}
+
+func New(cfg *Config) (*Logger, error) {
+	var format func(buf *bytes.Buffer, e *Entry)
+	switch cfg.Format {
+	case FormatCombined:
+		format = formatCombined
+	case FormatJSON:
+		format = formatJSON
+	default:
+		return nil, fmt.Errorf("unknown access log format %q", cfg.Format)
+	}
+
+	return &Logger{
+		format:     format,
+		sampleRate: cfg.SampleRate,
+		w:          cfg.Writer,
+	}, nil
+}
+
+// Sampled returns whether response with status should be logged
+func (l *Logger) Sampled(status int) bool {
+	return status >= http.StatusInternalServerError || l.sampleRate >= 1 || rand.Float64() < l.sampleRate
+}
+
+func (l *Logger) Log(e *Entry) error {
+	var buf bytes.Buffer
+	l.format(&buf, e)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, err := l.w.Write(buf.Bytes())
+	return err
+}
+
+// formatCombined writes entry in Apache Combined Log Format followed by latency and trace ids.
+// Fields sent by the client are escaped so these could not break the line.
+func formatCombined(buf *bytes.Buffer, e *Entry) {
+	fmt.Fprintf(buf, "%s - - [%s] \"%s %s %s\" %d %s \"%s\" \"%s\" latency_ms=%.3f trace_id=%s span_id=%s\n",
+		dash(e.RemoteAddr),
+		e.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		escape(e.Method), escape(e.URI), escape(e.Proto),
+		e.Status,
+		bytesField(e.Bytes),
+		dash(escape(e.Referer)),
+		dash(escape(e.UserAgent)),
+		e.LatencyMs,
+		dash(escape(e.TraceID)),
+		dash(escape(e.SpanID)))
+}
+
+// escape escapes quotes and backslashes with backslash and other non-printable bytes as \xNN like Apache does
+func escape(s string) string {
+	i := 0
+	for ; i < len(s); i++ {
+		if c := s[i]; c == '"' || c == '\\' || c < 0x20 || c >= 0x7f {
+			break
+		}
+	}
+	if i == len(s) {
+		return s
+	}
+
+	buf := make([]byte, 0, len(s)+8)
+	buf = append(buf, s[:i]...)
+	for ; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			buf = append(buf, '\\', c)
+		case c == '\n':
+			buf = append(buf, '\\', 'n')
+		case c == '\r':
+			buf = append(buf, '\\', 'r')
+		case c == '\t':
+			buf = append(buf, '\\', 't')
+		case c < 0x20 || c >= 0x7f:
+			buf = append(buf, '\\', 'x', hexDigits[c>>4], hexDigits[c&0xf])
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return string(buf)
+}
+
+const hexDigits = "0123456789abcdef"
+
+func formatJSON(buf *bytes.Buffer, e *Entry) {
+	// encoding of Entry could not fail
+	json.NewEncoder(buf).Encode(e)
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func bytesField(n int) string {
+	if n == 0 {
+		return "-"
+	}
+	return strconv.Itoa(n)
+}
@@ -0,0 +1,93 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type AccessLogSuite struct{}
+
+var _ = Suite(&AccessLogSuite{})
+
+// Hook up gocheck into the "go test" runner.
+func TestStart(t *testing.T) {
+	TestingT(t)
+}
+
+func newTestEntry() *Entry {
+	return &Entry{
+		Time:       time.Date(2016, time.October, 10, 13, 55, 36, 0, time.FixedZone("", -7*3600)),
+		RemoteAddr: "127.0.0.1",
+		Method:     "GET",
+		URI:        "/requestcount?format=text",
+		Proto:      "HTTP/1.1",
+		Status:     200,
+		Bytes:      2326,
+		LatencyMs:  1.5,
+		UserAgent:  "curl/7.50",
+		TraceID:    "ABC",
+		SpanID:     "DEF",
+	}
+}
+
+func (suite *AccessLogSuite) TestCombined(c *C) {
+	var buf bytes.Buffer
+	logger, err := New(&Config{Format: FormatCombined, SampleRate: 1, Writer: &buf})
+	c.Assert(err, IsNil)
+
+	c.Assert(logger.Log(newTestEntry()), IsNil)
+	c.Assert(buf.String(), Equals, `127.0.0.1 - - [10/Oct/2016:13:55:36 -0700] "GET /requestcount?format=text HTTP/1.1" 200 2326 "-" "curl/7.50" latency_ms=1.500 trace_id=ABC span_id=DEF`+"\n")
+}
+
+func (suite *AccessLogSuite) TestCombinedEscaping(c *C) {
+	var buf bytes.Buffer
+	logger, err := New(&Config{Format: FormatCombined, SampleRate: 1, Writer: &buf})
+	c.Assert(err, IsNil)
+
+	entry := newTestEntry()
+	entry.URI = `/requestcount?q="x"`
+	entry.Referer = `a\b`
+	entry.UserAgent = "evil\" 200 1 \"-\" \"-\"\n127.0.0.2 - - fake\x01\xff"
+	c.Assert(logger.Log(entry), IsNil)
+	c.Assert(buf.String(), Equals, `127.0.0.1 - - [10/Oct/2016:13:55:36 -0700] "GET /requestcount?q=\"x\" HTTP/1.1" 200 2326 "a\\b" "evil\" 200 1 \"-\" \"-\"\n127.0.0.2 - - fake\x01\xff" latency_ms=1.500 trace_id=ABC span_id=DEF`+"\n")
+}
+
+func (suite *AccessLogSuite) TestJSON(c *C) {
+	var buf bytes.Buffer
+	logger, err := New(&Config{Format: FormatJSON, SampleRate: 1, Writer: &buf})
+	c.Assert(err, IsNil)
+
+	c.Assert(logger.Log(newTestEntry()), IsNil)
+
+	var fields map[string]interface{}
+	c.Assert(json.Unmarshal(buf.Bytes(), &fields), IsNil)
+	c.Assert(fields["status"], Equals, float64(200))
+	c.Assert(fields["uri"], Equals, "/requestcount?format=text")
+	c.Assert(fields["trace_id"], Equals, "ABC")
+	c.Assert(fields["latency_ms"], Equals, 1.5)
+	_, hasReferer := fields["referer"]
+	c.Assert(hasReferer, Equals, false)
+}
+
+func (suite *AccessLogSuite) TestSampling(c *C) {
+	_, err := New(&Config{Format: "common"})
+	c.Assert(err, NotNil)
+
+	logger, err := New(&Config{Format: FormatJSON, SampleRate: 0.0001})
+	c.Assert(err, IsNil)
+
+	c.Assert(logger.Sampled(http.StatusInternalServerError), Equals, true)
+
+	var sampled int
+	for i := 0; i < 1000; i++ {
+		if logger.Sampled(http.StatusOK) {
+			sampled++
+		}
+	}
+	c.Assert(sampled < 10, Equals, true)
+}
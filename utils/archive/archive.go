@@ -0,0 +1,320 @@
+package archive
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	segmentExt = ".seg"
+
+	// each record is three little-endian uint64 values:
+	// bucket start (unix nano), bucket duration (nano) and count
+	recordSize = 3 * 8
+)
+
+// Record is a completed bucket
+type Record struct {
+	Start    time.Time
+	Duration time.Duration
+	Count    uint64
+}
+
+type Config struct {
+	Dir            string
+	SegmentSize    int64
+	SegmentAge     time.Duration
+	RetentionCount int
+	RetentionAge   time.Duration
+}
+
+// Archive appends completed buckets to append-only segment files.
+// Segment files are named by the unix nano timestamp of their creation
+// so lexicographical order of names is a chronological order.
+type Archive struct {
+	mu      sync.Mutex
+	cfg     Config
+	file    *os.File
+	size    int64
+	created time.Time
+	now     func() time.Time
+}
+
+func NewArchive(cfg *Config) *Archive {
+	return &Archive{
+		cfg: *cfg,
+		now: time.Now,
+	}
+}
+
+// Open creates archive directory if needed and opens the latest segment for appending
+func (a *Archive) Open() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := os.MkdirAll(a.cfg.Dir, 0755); err != nil {
+		return fmt.Errorf("error create archive dir: %s", err.Error())
+	}
+
+	segments, err := a.segments()
+	if err != nil {
+		return err
+	}
+
+	if len(segments) == 0 {
+		return a.createSegment()
+	}
+
+	last := segments[len(segments)-1]
+	file, err := os.OpenFile(a.segmentPath(last), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("error open segment: %s", err.Error())
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("error stat segment: %s", err.Error())
+	}
+
+	// drop partially written record if any
+	size := info.Size() - info.Size()%recordSize
+	if size != info.Size() {
+		if err := file.Truncate(size); err != nil {
+			file.Close()
+			return fmt.Errorf("error truncate segment: %s", err.Error())
+		}
+	}
+
+	a.file = file
+	a.size = size
+	a.created = time.Unix(0, last)
+
+	return nil
+}
+
+// Append writes record to the current segment rotating it if needed
+func (a *Archive) Append(rec Record) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.file == nil {
+		return fmt.Errorf("archive is closed")
+	}
+
+	if a.needRotate() {
+		if err := a.rotate(); err != nil {
+			return err
+		}
+	}
+
+	// a record partially written by the previous append is dropped if its truncate failed
+	if err := a.truncatePartial(); err != nil {
+		return err
+	}
+
+	var buf [recordSize]byte
+	binary.LittleEndian.PutUint64(buf[0:], uint64(rec.Start.UnixNano()))
+	binary.LittleEndian.PutUint64(buf[8:], uint64(rec.Duration))
+	binary.LittleEndian.PutUint64(buf[16:], rec.Count)
+
+	n, err := a.file.Write(buf[:])
+	a.size += int64(n)
+	if err != nil {
+		if truncErr := a.truncatePartial(); truncErr != nil {
+			return fmt.Errorf("error write segment: %s, %s", err.Error(), truncErr.Error())
+		}
+		return fmt.Errorf("error write segment: %s", err.Error())
+	}
+
+	return nil
+}
+
+// truncatePartial truncates the segment to the last full record so following records stay aligned
+func (a *Archive) truncatePartial() error {
+	size := a.size - a.size%recordSize
+	if size == a.size {
+		return nil
+	}
+
+	if err := a.file.Truncate(size); err != nil {
+		return fmt.Errorf("error truncate segment: %s", err.Error())
+	}
+	a.size = size
+
+	return nil
+}
+
+// Read returns all records which start in [from, to)
+func (a *Archive) Read(from, to time.Time) ([]Record, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	segments, err := a.segments()
+	if err != nil {
+		return nil, err
+	}
+
+	var records []Record
+	for i := range segments {
+		// records are appended after their buckets are completed, so a segment could not contain records
+		// newer than creation of the next segment, but it could contain records older than its own creation
+		if i+1 < len(segments) && segments[i+1] <= from.UnixNano() {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(a.segmentPath(segments[i]))
+		if err != nil {
+			return nil, fmt.Errorf("error read segment: %s", err.Error())
+		}
+
+		for len(data) >= recordSize {
+			rec := Record{
+				Start:    time.Unix(0, int64(binary.LittleEndian.Uint64(data[0:]))),
+				Duration: time.Duration(binary.LittleEndian.Uint64(data[8:])),
+				Count:    binary.LittleEndian.Uint64(data[16:]),
+			}
+			data = data[recordSize:]
+
+			if rec.Start.Before(from) || !rec.Start.Before(to) {
+				continue
+			}
+
+			records = append(records, rec)
+		}
+	}
+
+	return records, nil
+}
+
+func (a *Archive) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.file == nil {
+		return nil
+	}
+
+	err := a.file.Close()
+	a.file = nil
+
+	return err
+}
+
+func (a *Archive) needRotate() bool {
+	if a.cfg.SegmentSize > 0 && a.size+recordSize > a.cfg.SegmentSize {
+		return true
+	}
+
+	if a.cfg.SegmentAge > 0 && a.now().Sub(a.created) >= a.cfg.SegmentAge {
+		return true
+	}
+
+	return false
+}
+
+func (a *Archive) rotate() error {
+	if err := a.file.Close(); err != nil {
+		return fmt.Errorf("error close segment: %s", err.Error())
+	}
+	a.file = nil
+
+	if err := a.createSegment(); err != nil {
+		return err
+	}
+
+	return a.removeOutdated()
+}
+
+func (a *Archive) createSegment() error {
+	created := a.now()
+
+	segments, err := a.segments()
+	if err != nil {
+		return err
+	}
+
+	// keep names unique and ordered even if clock goes backwards
+	if len(segments) > 0 && segments[len(segments)-1] >= created.UnixNano() {
+		created = time.Unix(0, segments[len(segments)-1]+1)
+	}
+
+	file, err := os.OpenFile(a.segmentPath(created.UnixNano()), os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("error create segment: %s", err.Error())
+	}
+
+	a.file = file
+	a.size = 0
+	a.created = created
+
+	return nil
+}
+
+// removeOutdated removes segments exceeding retention count
+// and segments which contain only records older than retention age
+func (a *Archive) removeOutdated() error {
+	segments, err := a.segments()
+	if err != nil {
+		return err
+	}
+
+	remove := 0
+	if a.cfg.RetentionCount > 0 && len(segments) > a.cfg.RetentionCount {
+		remove = len(segments) - a.cfg.RetentionCount
+	}
+
+	if a.cfg.RetentionAge > 0 {
+		threshold := a.now().Add(-a.cfg.RetentionAge).UnixNano()
+		for remove < len(segments)-1 && segments[remove+1] <= threshold {
+			remove++
+		}
+	}
+
+	for _, segment := range segments[:remove] {
+		if err := os.Remove(a.segmentPath(segment)); err != nil {
+			return fmt.Errorf("error remove segment: %s", err.Error())
+		}
+	}
+
+	return nil
+}
+
+// segments returns sorted creation timestamps of all segments
+func (a *Archive) segments() ([]int64, error) {
+	infos, err := ioutil.ReadDir(a.cfg.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("error read archive dir: %s", err.Error())
+	}
+
+	var segments []int64
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+
+		created, err := strconv.ParseInt(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		segments = append(segments, created)
+	}
+
+	sort.Slice(segments, func(i, j int) bool { return segments[i] < segments[j] })
+
+	return segments, nil
+}
+
+func (a *Archive) segmentPath(created int64) string {
+	return filepath.Join(a.cfg.Dir, fmt.Sprintf("%020d%s", created, segmentExt))
+}
@@ -0,0 +1,157 @@
+package archive
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type ArchiveSuite struct {
+	dir string
+}
+
+var _ = Suite(&ArchiveSuite{})
+
+// Hook up gocheck into the "go test" runner.
+func TestStart(t *testing.T) {
+	TestingT(t)
+}
+
+func (suite *ArchiveSuite) SetUpTest(c *C) {
+	var err error
+	suite.dir, err = ioutil.TempDir("", "archive")
+	c.Assert(err, IsNil)
+}
+
+func (suite *ArchiveSuite) TearDownTest(c *C) {
+	os.RemoveAll(suite.dir)
+}
+
+func (suite *ArchiveSuite) newArchive(cfg Config, now *time.Time) *Archive {
+	cfg.Dir = suite.dir
+	a := NewArchive(&cfg)
+	a.now = func() time.Time {
+		return *now
+	}
+	return a
+}
+
+func (suite *ArchiveSuite) Test_AppendRead(c *C) {
+	now := time.Unix(100, 0)
+	a := suite.newArchive(Config{}, &now)
+	c.Assert(a.Open(), IsNil)
+
+	for i := 0; i < 5; i++ {
+		c.Assert(a.Append(Record{
+			Start:    time.Unix(int64(100+i), 0),
+			Duration: time.Second,
+			Count:    uint64(i),
+		}), IsNil)
+	}
+
+	records, err := a.Read(time.Unix(101, 0), time.Unix(104, 0))
+	c.Assert(err, IsNil)
+	c.Assert(records, HasLen, 3)
+	c.Assert(records[0].Start.Equal(time.Unix(101, 0)), Equals, true)
+	c.Assert(records[0].Duration, Equals, time.Second)
+	c.Assert(records[0].Count, Equals, uint64(1))
+	c.Assert(records[2].Count, Equals, uint64(3))
+
+	c.Assert(a.Close(), IsNil)
+
+	// reopen appends to the same segment
+	c.Assert(a.Open(), IsNil)
+	c.Assert(a.Append(Record{Start: time.Unix(105, 0), Duration: time.Second, Count: 5}), IsNil)
+	records, err = a.Read(time.Unix(0, 0), time.Unix(200, 0))
+	c.Assert(err, IsNil)
+	c.Assert(records, HasLen, 6)
+
+	segments, err := a.segments()
+	c.Assert(err, IsNil)
+	c.Assert(segments, HasLen, 1)
+	c.Assert(a.Close(), IsNil)
+}
+
+func (suite *ArchiveSuite) Test_RotateBySize(c *C) {
+	now := time.Unix(100, 0)
+	a := suite.newArchive(Config{SegmentSize: 2 * recordSize, RetentionCount: 2}, &now)
+	c.Assert(a.Open(), IsNil)
+
+	for i := 0; i < 7; i++ {
+		now = now.Add(time.Second)
+		c.Assert(a.Append(Record{Start: now, Duration: time.Second, Count: 1}), IsNil)
+	}
+
+	segments, err := a.segments()
+	c.Assert(err, IsNil)
+	c.Assert(segments, HasLen, 2)
+
+	records, err := a.Read(time.Unix(0, 0), time.Unix(200, 0))
+	c.Assert(err, IsNil)
+	c.Assert(records, HasLen, 3)
+	c.Assert(a.Close(), IsNil)
+}
+
+func (suite *ArchiveSuite) Test_RotateByAge(c *C) {
+	now := time.Unix(100, 0)
+	a := suite.newArchive(Config{SegmentAge: 10 * time.Second, RetentionAge: 15 * time.Second}, &now)
+	c.Assert(a.Open(), IsNil)
+
+	for i := 0; i < 30; i++ {
+		now = now.Add(time.Second)
+		c.Assert(a.Append(Record{Start: now, Duration: time.Second, Count: 1}), IsNil)
+	}
+
+	segments, err := a.segments()
+	c.Assert(err, IsNil)
+	c.Assert(segments, HasLen, 3)
+
+	records, err := a.Read(time.Unix(0, 0), time.Unix(200, 0))
+	c.Assert(err, IsNil)
+	c.Assert(records, HasLen, 21)
+	c.Assert(records[0].Start.Equal(time.Unix(110, 0)), Equals, true)
+	c.Assert(a.Close(), IsNil)
+}
+
+func (suite *ArchiveSuite) Test_ReadRecordsOlderThanSegment(c *C) {
+	now := time.Unix(100, 0)
+	a := suite.newArchive(Config{SegmentAge: 10 * time.Second}, &now)
+	c.Assert(a.Open(), IsNil)
+
+	// the bucket started before the segment is rotated on its append
+	now = time.Unix(110, 0)
+	c.Assert(a.Append(Record{Start: time.Unix(109, 0), Duration: time.Second, Count: 1}), IsNil)
+
+	segments, err := a.segments()
+	c.Assert(err, IsNil)
+	c.Assert(segments, HasLen, 2)
+
+	records, err := a.Read(time.Unix(105, 0), time.Unix(110, 0))
+	c.Assert(err, IsNil)
+	c.Assert(records, HasLen, 1)
+	c.Assert(records[0].Start.Equal(time.Unix(109, 0)), Equals, true)
+	c.Assert(a.Close(), IsNil)
+}
+
+func (suite *ArchiveSuite) Test_AppendAfterPartialWrite(c *C) {
+	now := time.Unix(100, 0)
+	a := suite.newArchive(Config{}, &now)
+	c.Assert(a.Open(), IsNil)
+	c.Assert(a.Append(Record{Start: time.Unix(100, 0), Duration: time.Second, Count: 1}), IsNil)
+
+	// simulate a short write which could not be truncated
+	n, err := a.file.Write([]byte{1, 2, 3})
+	c.Assert(err, IsNil)
+	a.size += int64(n)
+
+	c.Assert(a.Append(Record{Start: time.Unix(101, 0), Duration: time.Second, Count: 2}), IsNil)
+
+	records, err := a.Read(time.Unix(0, 0), time.Unix(200, 0))
+	c.Assert(err, IsNil)
+	c.Assert(records, HasLen, 2)
+	c.Assert(records[1].Count, Equals, uint64(2))
+	c.Assert(a.Close(), IsNil)
+}
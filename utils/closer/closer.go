@@ -11,9 +11,16 @@ type ICloser interface {
 	Close() error
 }
 
+// IReopener defines outputs which reopen their files after these are moved by external tools (e.g. logrotate)
+type IReopener interface {
+	Reopen() error
+}
+
 type Closer struct {
-	mu      sync.Mutex
-	closers []ICloser
+	mu        sync.Mutex
+	closers   []ICloser
+	reopeners []IReopener
+	onReopen  func(err error)
 }
 
 func NewCloser() *Closer {
@@ -26,13 +33,57 @@ func (c *Closer) AddCloser(closer ICloser) {
 	c.mu.Unlock()
 }
 
+// AddReopener adds reopener called on SIGUSR1
+func (c *Closer) AddReopener(reopener IReopener) {
+	c.mu.Lock()
+	c.reopeners = append(c.reopeners, reopener)
+	c.mu.Unlock()
+}
+
+// OnReopen sets function called after reopening with the first error or nil
+func (c *Closer) OnReopen(f func(err error)) {
+	c.mu.Lock()
+	c.onReopen = f
+	c.mu.Unlock()
+}
+
+// Run reopens files on SIGUSR1 until a shutdown signal (SIGHUP, SIGINT or SIGQUIT) is received,
+// then closes closers and returns the signal
 func (c *Closer) Run() (os.Signal, error) {
 	ch := make(chan os.Signal, 1)
-	signal.Notify(ch, syscall.SIGHUP, syscall.SIGINT, syscall.SIGQUIT)
+	signal.Notify(ch, syscall.SIGHUP, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGUSR1)
+	defer signal.Stop(ch)
+
+	for {
+		sig := <-ch
+		if sig == syscall.SIGUSR1 {
+			c.reopen()
+			continue
+		}
 
-	sig := <-ch
+		return sig, c.close()
+	}
+}
+
+func (c *Closer) reopen() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var firstErr error
+	for _, reopener := range c.reopeners {
+		if err := reopener.Reopen(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	if c.onReopen != nil {
+		c.onReopen(firstErr)
+	}
+}
 
-	return sig, c.close()
+// Close closes closers without waiting for a signal
+func (c *Closer) Close() error {
+	return c.close()
 }
 
 func (c *Closer) close() error {
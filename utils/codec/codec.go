@@ -0,0 +1,202 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var ErrUnsupported = errors.New("response could not be represented in requested format")
+
+// IPlainText defines data having plain text representation
+type IPlainText interface {
+	PlainText() string
+}
+
+// ICSV defines data having tabular representation, the first record is a header
+type ICSV interface {
+	CSV() [][]string
+}
+
+type Encoder struct {
+	// Format is a value of format query param selecting the encoder
+	Format      string
+	ContentType string
+	Encode      func(data interface{}, pretty bool) ([]byte, error)
+	// supports returns false for data Encode fails with ErrUnsupported, nil supports any data
+	supports func(data interface{}) bool
+}
+
+// Supports returns true if data could be encoded, data could be a zero value of the type to encode
+func (enc *Encoder) Supports(data interface{}) bool {
+	return enc.supports == nil || enc.supports(data)
+}
+
+// encoders are listed in order of preference for wildcard Accept
+var encoders = []*Encoder{
+	{Format: "json", ContentType: "application/json", Encode: encodeJSON},
+	{Format: "text", ContentType: "text/plain", Encode: encodeText, supports: isPlainText},
+	{Format: "csv", ContentType: "text/csv", Encode: encodeCSV, supports: isCSV},
+	{Format: "msgpack", ContentType: "application/msgpack", Encode: func(data interface{}, _ bool) ([]byte, error) {
+		return encodeMsgpack(data)
+	}},
+}
+
+// aliases are content types accepted in addition to Encoder.ContentType
+var aliases = map[string]string{
+	"application/x-msgpack": "application/msgpack",
+}
+
+var JSON = encoders[0]
+
+// Negotiate returns encoder selected by format if it is not empty or by Accept header otherwise
+func Negotiate(accept, format string) (*Encoder, bool) {
+	if format != "" {
+		for _, enc := range encoders {
+			if enc.Format == format {
+				return enc, true
+			}
+		}
+		return nil, false
+	}
+
+	if strings.TrimSpace(accept) == "" {
+		return JSON, true
+	}
+
+	// quality of the content type is taken from the most specific matching range,
+	// so application/json;q=0 excludes json even if */* is accepted
+	ranges := parseAccept(accept)
+	var best *Encoder
+	bestQuality, bestRange := 0.0, len(ranges)
+	for _, enc := range encoders {
+		matched, specificity := -1, 0
+		for i, rangeValue := range ranges {
+			if s := rangeValue.specificity(enc.ContentType); s > specificity {
+				matched, specificity = i, s
+			}
+		}
+
+		if matched < 0 || ranges[matched].quality <= 0 {
+			continue
+		}
+
+		quality := ranges[matched].quality
+		if quality > bestQuality || quality == bestQuality && matched < bestRange {
+			best, bestQuality, bestRange = enc, quality, matched
+		}
+	}
+
+	return best, best != nil
+}
+
+type mediaRange struct {
+	mediaType string
+	quality   float64
+}
+
+// specificity returns 0 if the range does not match content type,
+// otherwise the more specific the range is the greater is the result
+func (mr mediaRange) specificity(contentType string) int {
+	if alias, ok := aliases[mr.mediaType]; ok {
+		if alias == contentType {
+			return 3
+		}
+		return 0
+	}
+
+	switch {
+	case mr.mediaType == contentType:
+		return 3
+	case strings.HasSuffix(mr.mediaType, "/*") && mr.mediaType != "*/*" && strings.HasPrefix(contentType, strings.TrimSuffix(mr.mediaType, "*")):
+		return 2
+	case mr.mediaType == "*/*":
+		return 1
+	}
+	return 0
+}
+
+// parseAccept returns media ranges of Accept header value ordered by quality
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+
+		ranges = append(ranges, mediaRange{mediaType: mediaType, quality: quality})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].quality > ranges[j].quality
+	})
+
+	return ranges
+}
+
+func encodeJSON(data interface{}, pretty bool) ([]byte, error) {
+	if pretty {
+		return json.MarshalIndent(data, "", "    ")
+	}
+	return json.Marshal(data)
+}
+
+func isPlainText(data interface{}) bool {
+	switch data.(type) {
+	case IPlainText, error:
+		return true
+	}
+	return false
+}
+
+func isCSV(data interface{}) bool {
+	_, ok := data.(ICSV)
+	return ok
+}
+
+func encodeText(data interface{}, _ bool) ([]byte, error) {
+	switch v := data.(type) {
+	case IPlainText:
+		return []byte(v.PlainText() + "\n"), nil
+	case error:
+		return []byte(v.Error() + "\n"), nil
+	}
+	return nil, ErrUnsupported
+}
+
+func encodeCSV(data interface{}, _ bool) ([]byte, error) {
+	table, ok := data.(ICSV)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.WriteAll(table.CSV()); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ContentTypes returns comma separated list of supported content types
+func ContentTypes() string {
+	types := make([]string, 0, len(encoders))
+	for _, enc := range encoders {
+		types = append(types, enc.ContentType)
+	}
+	return strings.Join(types, ", ")
+}
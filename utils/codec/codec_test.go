@@ -0,0 +1,115 @@
+package codec
+
+import (
+	"errors"
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+type CodecSuite struct{}
+
+var _ = Suite(&CodecSuite{})
+
+// Hook up gocheck into the "go test" runner.
+func TestStart(t *testing.T) {
+	TestingT(t)
+}
+
+func (suite *CodecSuite) Test_Negotiate(c *C) {
+	for _, tc := range []struct {
+		accept      string
+		format      string
+		contentType string
+	}{
+		{"", "", "application/json"},
+		{"*/*", "", "application/json"},
+		{"text/*", "", "text/plain"},
+		{"text/csv", "", "text/csv"},
+		{"application/x-msgpack", "", "application/msgpack"},
+		{"text/html, text/plain;q=0.5, application/json;q=0.9", "", "application/json"},
+		{"text/csv;q=0.1, text/plain", "", "text/plain"},
+		// the most specific range sets the quality
+		{"application/json;q=0, */*", "", "text/plain"},
+		{"*/*, text/plain;q=0", "", "application/json"},
+		{"text/*;q=0.5, text/csv, */*;q=0.1", "", "text/csv"},
+		{"text/*, text/plain;q=0", "", "text/csv"},
+		{"application/json", "csv", "text/csv"},
+		{"text/html", "msgpack", "application/msgpack"},
+	} {
+		enc, ok := Negotiate(tc.accept, tc.format)
+		c.Assert(ok, Equals, true, Commentf("accept: %s format: %s", tc.accept, tc.format))
+		c.Assert(enc.ContentType, Equals, tc.contentType, Commentf("accept: %s format: %s", tc.accept, tc.format))
+	}
+
+	_, ok := Negotiate("text/html, application/json;q=0", "")
+	c.Assert(ok, Equals, false)
+
+	_, ok = Negotiate("*/*, application/json;q=0, text/*;q=0, application/msgpack;q=0", "")
+	c.Assert(ok, Equals, false)
+
+	_, ok = Negotiate("", "xml")
+	c.Assert(ok, Equals, false)
+}
+
+type count uint64
+
+func (n count) PlainText() string {
+	return "42"
+}
+
+func (suite *CodecSuite) Test_Encode(c *C) {
+	data, err := encodeText(count(42), false)
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "42\n")
+
+	data, err = encodeText(errors.New("failed"), false)
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "failed\n")
+
+	_, err = encodeText(struct{}{}, false)
+	c.Assert(err, Equals, ErrUnsupported)
+
+	_, err = encodeCSV(count(42), false)
+	c.Assert(err, Equals, ErrUnsupported)
+
+	for _, enc := range encoders {
+		_, err = enc.Encode(struct{}{}, false)
+		c.Assert(enc.Supports(struct{}{}), Equals, err != ErrUnsupported, Commentf("format: %s", enc.Format))
+	}
+
+	text, _ := Negotiate("", "text")
+	c.Assert(text.Supports(count(0)), Equals, true)
+	c.Assert(text.Supports(errors.New("failed")), Equals, true)
+	c.Assert(text.Supports(struct{}{}), Equals, false)
+	c.Assert(JSON.Supports(struct{}{}), Equals, true)
+
+	data, err = encodeJSON(map[string]int{"count": 1}, true)
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "{\n    \"count\": 1\n}")
+}
+
+func (suite *CodecSuite) Test_Msgpack(c *C) {
+	data, err := encodeMsgpack(struct {
+		Count    uint64   `json:"count"`
+		Negative int      `json:"negative"`
+		Big      uint64   `json:"big"`
+		Value    float64  `json:"value"`
+		Name     string   `json:"name"`
+		Tags     []string `json:"tags"`
+		Ok       bool     `json:"ok"`
+		Empty    *int     `json:"empty"`
+	}{Count: 3, Negative: -200, Big: 1 << 63, Value: 0.5, Name: "a", Tags: []string{"b"}, Ok: true})
+	c.Assert(err, IsNil)
+	c.Assert(data, DeepEquals, []byte{
+		0x88,
+		0xa3, 'b', 'i', 'g', 0xcf, 0x80, 0, 0, 0, 0, 0, 0, 0,
+		0xa5, 'c', 'o', 'u', 'n', 't', 0x03,
+		0xa5, 'e', 'm', 'p', 't', 'y', 0xc0,
+		0xa4, 'n', 'a', 'm', 'e', 0xa1, 'a',
+		0xa8, 'n', 'e', 'g', 'a', 't', 'i', 'v', 'e', 0xd3, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x38,
+		0xa2, 'o', 'k', 0xc3,
+		0xa4, 't', 'a', 'g', 's', 0x91, 0xa1, 'b',
+		0xa5, 'v', 'a', 'l', 'u', 'e', 0xcb, 0x3f, 0xe0, 0, 0, 0, 0, 0, 0,
+	})
+}
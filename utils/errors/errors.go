@@ -1,18 +1,51 @@
 package errors
 
 import (
+	"encoding/json"
+	stderrors "errors"
 	"fmt"
+	"net/http"
+	"strings"
+	"sync"
 )
 
+// ProblemContentType is a content type of errors rendered as JSON (RFC 7807)
+const ProblemContentType = "application/problem+json"
+
+// CodedError is an error with HTTP status code, machine-readable reason and optional details.
+// Wrapped cause is available to errors.Is and errors.As.
 type CodedError struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+	Code    int
+	Reason  string
+	Message string
+	Details map[string]interface{}
+	Cause   error
+}
+
+type registered struct {
+	code   int
+	reason string
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[error]registered)
+)
+
+// Register maps sentinel err to status code and reason used by From
+func Register(err error, code int, reason string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	registry[err] = registered{code: code, reason: reason}
 }
 
+// Wrap returns error with status code and message of err wrapping it
 func Wrap(err error, code int) *CodedError {
 	return &CodedError{
 		Code:    code,
 		Message: err.Error(),
+		Cause:   err,
 	}
 }
 
@@ -26,10 +59,45 @@ func New(code int, msg string) *CodedError {
 func Newf(code int, format string, args ...interface{}) *CodedError {
 	return &CodedError{
 		Code:    code,
-		Message: fmt.Sprintf(format, args),
+		Message: fmt.Sprintf(format, args...),
 	}
 }
 
+// From returns CodedError found in the chain of err, registered sentinel error
+// or internal server error if err is unknown
+func From(err error) *CodedError {
+	var codedErr *CodedError
+	if stderrors.As(err, &codedErr) {
+		return codedErr
+	}
+
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	for sentinel, r := range registry {
+		if stderrors.Is(err, sentinel) {
+			return Wrap(err, r.code).WithReason(r.reason)
+		}
+	}
+
+	return Wrap(err, http.StatusInternalServerError)
+}
+
+// WithReason sets machine-readable reason
+func (ce *CodedError) WithReason(reason string) *CodedError {
+	ce.Reason = reason
+	return ce
+}
+
+// WithDetail adds detail rendered in details object
+func (ce *CodedError) WithDetail(key string, value interface{}) *CodedError {
+	if ce.Details == nil {
+		ce.Details = make(map[string]interface{})
+	}
+	ce.Details[key] = value
+	return ce
+}
+
 func (ce *CodedError) String() string {
 	return ce.Message
 }
@@ -38,6 +106,39 @@ func (ce *CodedError) Error() string {
 	return ce.Message
 }
 
+func (ce *CodedError) Unwrap() error {
+	return ce.Cause
+}
+
 func (ce *CodedError) GetHttpCode() int {
 	return ce.Code
 }
+
+// GetReason returns machine-readable reason, it is derived from status code if not set
+func (ce *CodedError) GetReason() string {
+	if ce.Reason != "" {
+		return ce.Reason
+	}
+	return strings.ToLower(strings.Replace(http.StatusText(ce.Code), " ", "_", -1))
+}
+
+type problem struct {
+	Type    string                 `json:"type"`
+	Title   string                 `json:"title"`
+	Status  int                    `json:"status"`
+	Detail  string                 `json:"detail,omitempty"`
+	Code    string                 `json:"code"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// MarshalJSON renders error as problem details object (RFC 7807)
+func (ce *CodedError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&problem{
+		Type:    "about:blank",
+		Title:   http.StatusText(ce.Code),
+		Status:  ce.Code,
+		Detail:  ce.Message,
+		Code:    ce.GetReason(),
+		Details: ce.Details,
+	})
+}
@@ -0,0 +1,52 @@
+package errors
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+type ErrorsSuite struct{}
+
+var _ = Suite(&ErrorsSuite{})
+
+// Hook up gocheck into the "go test" runner.
+func TestStart(t *testing.T) {
+	TestingT(t)
+}
+
+var errSentinel = stderrors.New("sentinel")
+
+func init() {
+	Register(errSentinel, http.StatusConflict, "sentinel_conflict")
+}
+
+func (suite *ErrorsSuite) Test_From(c *C) {
+	codedErr := From(fmt.Errorf("context: %w", errSentinel))
+	c.Assert(codedErr.GetHttpCode(), Equals, http.StatusConflict)
+	c.Assert(codedErr.GetReason(), Equals, "sentinel_conflict")
+	c.Assert(codedErr.Error(), Equals, "context: sentinel")
+	c.Assert(stderrors.Is(codedErr, errSentinel), Equals, true)
+
+	notFound := New(http.StatusNotFound, "missing")
+	c.Assert(From(fmt.Errorf("wrapped: %w", notFound)), Equals, notFound)
+
+	codedErr = From(stderrors.New("unexpected"))
+	c.Assert(codedErr.GetHttpCode(), Equals, http.StatusInternalServerError)
+	c.Assert(codedErr.GetReason(), Equals, "internal_server_error")
+
+	c.Assert(Newf(http.StatusBadRequest, "invalid %s: %d", "limit", 5).Error(), Equals, "invalid limit: 5")
+}
+
+func (suite *ErrorsSuite) Test_Problem(c *C) {
+	data, err := json.Marshal(New(http.StatusTooManyRequests, "too many keys").
+		WithReason("too_many_keys").
+		WithDetail("limit", 1000))
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, `{"type":"about:blank","title":"Too Many Requests","status":429,`+
+		`"detail":"too many keys","code":"too_many_keys","details":{"limit":1000}}`)
+}
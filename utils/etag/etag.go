@@ -0,0 +1,28 @@
+package etag
+
+import (
+	"strings"
+)
+
+// Tags returns entity tags listed in If-None-Match header value without weakness indicators
+func Tags(ifNoneMatch string) []string {
+	var tags []string
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// Matches returns whether If-None-Match header value matches etag using weak comparison
+func Matches(ifNoneMatch, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range Tags(ifNoneMatch) {
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
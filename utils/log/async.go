@@ -0,0 +1,202 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/THE108/requestcounter/utils/metrics"
+)
+
+// Overflow policies of AsyncWriter
+const (
+	// BlockPolicy makes writers wait until the queue has space
+	BlockPolicy = "block"
+	// DropNewestPolicy drops the message written to the full queue
+	DropNewestPolicy = "drop-newest"
+	// DropDebugPolicy drops DEBUG messages (the written one or the oldest queued one) if the queue is full,
+	// other messages wait until the queue has space
+	DropDebugPolicy = "drop-debug"
+)
+
+// ILevelWriter defines outputs which handle messages depending on their level
+type ILevelWriter interface {
+	WriteLevel(level int, p []byte) (int, error)
+}
+
+type asyncMessage struct {
+	level int
+	data  []byte
+}
+
+type AsyncConfig struct {
+	Out       io.Writer
+	QueueSize int
+	Policy    string
+}
+
+// AsyncWriter writes messages to the output in the background goroutine.
+// Messages written after Close are written synchronously.
+type AsyncWriter struct {
+	out       io.Writer
+	queueSize int
+	policy    string
+
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	queue    []asyncMessage
+	closed   bool
+	dropped  uint64
+	done     chan struct{}
+}
+
+// NewAsyncWriter starts the background goroutine
+func NewAsyncWriter(cfg *AsyncConfig) (*AsyncWriter, error) {
+	switch cfg.Policy {
+	case BlockPolicy, DropNewestPolicy, DropDebugPolicy:
+	default:
+		return nil, fmt.Errorf("unknown log overflow policy %q", cfg.Policy)
+	}
+
+	if cfg.QueueSize <= 0 {
+		return nil, fmt.Errorf("log queue size must be positive")
+	}
+
+	w := &AsyncWriter{
+		out:       cfg.Out,
+		queueSize: cfg.QueueSize,
+		policy:    cfg.Policy,
+		queue:     make([]asyncMessage, 0, cfg.QueueSize),
+		done:      make(chan struct{}),
+	}
+	w.notEmpty = sync.NewCond(&w.mu)
+	w.notFull = sync.NewCond(&w.mu)
+
+	go w.run()
+
+	return w, nil
+}
+
+// Write queues message of unknown level, it is handled as INFO one
+func (w *AsyncWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(INFO, p)
+}
+
+// WriteLevel queues copy of p, the written message is dropped or the call blocks if the queue is full
+func (w *AsyncWriter) WriteLevel(level int, p []byte) (int, error) {
+	w.mu.Lock()
+
+	for !w.closed && len(w.queue) >= w.queueSize {
+		if w.policy == DropNewestPolicy || (w.policy == DropDebugPolicy && level == DEBUG) {
+			w.dropped++
+			w.mu.Unlock()
+			return len(p), nil
+		}
+
+		if w.policy == DropDebugPolicy && w.dropQueuedDebug() {
+			break
+		}
+
+		w.notFull.Wait()
+	}
+
+	if w.closed {
+		w.mu.Unlock()
+		// queued messages are written first
+		<-w.done
+		return w.write(level, p)
+	}
+
+	data := append(getBytes(), p...)
+	w.queue = append(w.queue, asyncMessage{level: level, data: data})
+	w.notEmpty.Signal()
+	w.mu.Unlock()
+
+	return len(p), nil
+}
+
+// dropQueuedDebug removes the oldest queued DEBUG message, mu must be held
+func (w *AsyncWriter) dropQueuedDebug() bool {
+	for i, msg := range w.queue {
+		if msg.level == DEBUG {
+			putBytes(msg.data)
+			copy(w.queue[i:], w.queue[i+1:])
+			w.queue = w.queue[:len(w.queue)-1]
+			w.dropped++
+			return true
+		}
+	}
+	return false
+}
+
+func (w *AsyncWriter) run() {
+	defer close(w.done)
+
+	batch := make([]asyncMessage, 0, w.queueSize)
+	for {
+		w.mu.Lock()
+		for len(w.queue) == 0 && !w.closed {
+			w.notEmpty.Wait()
+		}
+
+		if len(w.queue) == 0 {
+			w.mu.Unlock()
+			return
+		}
+
+		batch = append(batch[:0], w.queue...)
+		w.queue = w.queue[:0]
+		w.notFull.Broadcast()
+		w.mu.Unlock()
+
+		for _, msg := range batch {
+			// nothing could be done with the error, the output is the log itself
+			w.write(msg.level, msg.data)
+			putBytes(msg.data)
+		}
+	}
+}
+
+// write passes level to the output if it handles levels
+func (w *AsyncWriter) write(level int, p []byte) (int, error) {
+	if lw, ok := w.out.(ILevelWriter); ok {
+		return lw.WriteLevel(level, p)
+	}
+	return w.out.Write(p)
+}
+
+// Dropped returns count of dropped messages
+func (w *AsyncWriter) Dropped() uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.dropped
+}
+
+func (w *AsyncWriter) WriteMetrics(mw *metrics.Writer) {
+	w.mu.Lock()
+	dropped, queued := w.dropped, len(w.queue)
+	w.mu.Unlock()
+
+	mw.Header("log_dropped_messages_total", metrics.Counter, "Count of log messages dropped because the queue was full.")
+	mw.Sample("log_dropped_messages_total", float64(dropped))
+	mw.Header("log_queued_messages", metrics.Gauge, "Count of log messages waiting to be written.")
+	mw.Sample("log_queued_messages", float64(queued))
+}
+
+// Close writes queued messages and stops the background goroutine
+func (w *AsyncWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.notEmpty.Broadcast()
+	w.notFull.Broadcast()
+	w.mu.Unlock()
+
+	<-w.done
+	return nil
+}
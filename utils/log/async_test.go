@@ -0,0 +1,105 @@
+package log
+
+import (
+	"bytes"
+	"sync"
+
+	. "gopkg.in/check.v1"
+)
+
+// gatedWriter blocks writes until the gate is opened
+type gatedWriter struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	gate    chan struct{}
+	started chan struct{}
+}
+
+func newGatedWriter() *gatedWriter {
+	return &gatedWriter{gate: make(chan struct{}), started: make(chan struct{}, 100)}
+}
+
+func (gw *gatedWriter) Write(p []byte) (int, error) {
+	gw.started <- struct{}{}
+	<-gw.gate
+
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+	return gw.buf.Write(p)
+}
+
+func (gw *gatedWriter) String() string {
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+	return gw.buf.String()
+}
+
+// fill makes the background goroutine block on the first message and queues size more
+func fill(c *C, w *AsyncWriter, gw *gatedWriter, levels ...int) {
+	w.WriteLevel(INFO, []byte("first\n"))
+	<-gw.started
+
+	for i, level := range levels {
+		w.WriteLevel(level, []byte{byte('a' + i), '\n'})
+	}
+}
+
+func (suite *LoggerSuite) TestAsyncDropNewest(c *C) {
+	gw := newGatedWriter()
+	w, err := NewAsyncWriter(&AsyncConfig{Out: gw, QueueSize: 2, Policy: DropNewestPolicy})
+	c.Assert(err, IsNil)
+
+	fill(c, w, gw, INFO, DEBUG, ERROR)
+	c.Assert(w.Dropped(), Equals, uint64(1))
+
+	close(gw.gate)
+	c.Assert(w.Close(), IsNil)
+	c.Assert(gw.String(), Equals, "first\na\nb\n")
+
+	// messages written after close are written synchronously
+	w.Write([]byte("late\n"))
+	c.Assert(gw.String(), Equals, "first\na\nb\nlate\n")
+}
+
+func (suite *LoggerSuite) TestAsyncDropDebug(c *C) {
+	gw := newGatedWriter()
+	w, err := NewAsyncWriter(&AsyncConfig{Out: gw, QueueSize: 3, Policy: DropDebugPolicy})
+	c.Assert(err, IsNil)
+
+	// c replaces queued b, debug d is dropped
+	fill(c, w, gw, INFO, DEBUG, WARNING, DEBUG, ERROR)
+	c.Assert(w.Dropped(), Equals, uint64(2))
+
+	close(gw.gate)
+	c.Assert(w.Close(), IsNil)
+	c.Assert(gw.String(), Equals, "first\na\nc\ne\n")
+}
+
+func (suite *LoggerSuite) TestAsyncBlock(c *C) {
+	gw := newGatedWriter()
+	w, err := NewAsyncWriter(&AsyncConfig{Out: gw, QueueSize: 1, Policy: BlockPolicy})
+	c.Assert(err, IsNil)
+
+	fill(c, w, gw, DEBUG)
+
+	written := make(chan struct{})
+	go func() {
+		NewWithLevel(w, "", NewLevel(DEBUG), NewTextEncoder(0, true)).Error("blocked")
+		close(written)
+	}()
+
+	select {
+	case <-written:
+		c.Fatal("write to the full queue must block")
+	default:
+	}
+
+	close(gw.gate)
+	<-written
+	c.Assert(w.Close(), IsNil)
+	c.Assert(w.Dropped(), Equals, uint64(0))
+	c.Assert(gw.String(), Equals, "first\na\n[E] blocked\n")
+
+	_, err = NewAsyncWriter(&AsyncConfig{Out: gw, QueueSize: 1, Policy: "drop-oldest"})
+	c.Assert(err, NotNil)
+}
@@ -3,6 +3,8 @@ package log
 import (
 	"os"
 
+	"github.com/THE108/requestcounter/utils/tracedata"
+
 	"golang.org/x/net/context"
 )
 
@@ -10,7 +12,16 @@ type loggerCtxKeyType int
 
 const loggerCtxKey loggerCtxKeyType = 0
 
+// SetLoggerToContext stores logger to the context, trace data of the context is added to the logger as fields
 func SetLoggerToContext(ctx context.Context, logger ILogger) context.Context {
+	if traceData := tracedata.GetTraceDataFromContext(ctx); traceData.TraceID != "" {
+		fields := []Field{String(TraceIDKey, traceData.TraceID), String(SpanIDKey, traceData.SpanID)}
+		if traceData.ParentSpanID != "" {
+			fields = append(fields, String(ParentSpanIDKey, traceData.ParentSpanID))
+		}
+		logger = logger.With(fields...)
+	}
+
 	return context.WithValue(ctx, loggerCtxKey, logger)
 }
 
@@ -50,3 +50,7 @@ func (l *devNullLogger) Errorf(format string, v ...interface{}) {
 // Arguments are handled in the manner of fmt.Printf.
 func (l *devNullLogger) ErrorIfNotNil(message string, err error) {
 }
+
+func (l *devNullLogger) With(fields ...Field) ILogger {
+	return l
+}
@@ -0,0 +1,176 @@
+package log
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats of log output
+const (
+	TextFormat = "text"
+	JSONFormat = "json"
+)
+
+// Keys of trace data fields added to loggers of requests
+const (
+	TraceIDKey      = "trace_id"
+	SpanIDKey       = "span_id"
+	ParentSpanIDKey = "parent_span_id"
+)
+
+var levelNames = [4]string{"debug", "info", "warning", "error"}
+
+// Entry is a single log record passed to encoder
+type Entry struct {
+	Time    time.Time
+	Level   int
+	Prefix  string
+	File    string
+	Line    int
+	Message string
+	Fields  []Field
+}
+
+// IEncoder appends encoded entry terminated by a newline to buf
+type IEncoder interface {
+	Encode(buf []byte, entry *Entry) []byte
+}
+
+// NewEncoder returns encoder of format, text encoder is used for unknown formats.
+// traceFields makes text encoder write trace data as key=value pairs instead of the traceid|spanid|parent prefix.
+func NewEncoder(format string, traceFields bool) IEncoder {
+	if format == JSONFormat {
+		return NewJSONEncoder()
+	}
+	return NewTextEncoder(LstdFlags, traceFields)
+}
+
+type textEncoder struct {
+	flag        int
+	traceFields bool
+}
+
+// NewTextEncoder returns encoder writing header defined by flag, message and fields as key=value pairs:
+//
+//	2009.01.23 01:23:23 prefix [I] message key=value key="value with spaces"
+//
+// Trace data replaces the prefix as traceid|spanid|parent unless traceFields is set:
+//
+//	2009.01.23 01:23:23 4bf92f35...|e457b5a2e4d86bd1|00f067aa0ba902b7 [I] message key=value
+func NewTextEncoder(flag int, traceFields bool) IEncoder {
+	return &textEncoder{flag: flag, traceFields: traceFields}
+}
+
+func (enc *textEncoder) Encode(buf []byte, entry *Entry) []byte {
+	prefix, fields := entry.Prefix, entry.Fields
+	if !enc.traceFields {
+		prefix, fields = tracePrefix(prefix, fields)
+	}
+
+	formatHeader(&buf, entry.Time, entry.File, prefix, entry.Line, entry.Level, enc.flag)
+
+	msg := strings.TrimSuffix(entry.Message, "\n")
+	buf = append(buf, msg...)
+
+	for _, field := range fields {
+		if len(buf) > 0 && buf[len(buf)-1] != ' ' {
+			buf = append(buf, ' ')
+		}
+		buf = append(buf, field.Key...)
+		buf = append(buf, '=')
+
+		value := field.text()
+		if value == "" || strings.ContainsAny(value, " =\"\n\t") {
+			buf = strconv.AppendQuote(buf, value)
+		} else {
+			buf = append(buf, value...)
+		}
+	}
+
+	return append(buf, '\n')
+}
+
+// tracePrefix returns traceid|spanid|parent prefix and fields without trace data,
+// prefix and fields are returned as is if there is no trace data
+func tracePrefix(prefix string, fields []Field) (string, []Field) {
+	var traceID, spanID, parentSpanID string
+	rest := make([]Field, 0, len(fields))
+	for _, field := range fields {
+		switch field.Key {
+		case TraceIDKey:
+			traceID = field.Str
+		case SpanIDKey:
+			spanID = field.Str
+		case ParentSpanIDKey:
+			parentSpanID = field.Str
+		default:
+			rest = append(rest, field)
+		}
+	}
+
+	if traceID == "" {
+		return prefix, fields
+	}
+	return traceID + "|" + spanID + "|" + parentSpanID, rest
+}
+
+type jsonEncoder struct{}
+
+// NewJSONEncoder returns encoder writing one JSON object per line:
+//
+//	{"time":"2009-01-23T01:23:23.123123Z","level":"info","logger":"prefix","msg":"message","key":"value"}
+func NewJSONEncoder() IEncoder {
+	return &jsonEncoder{}
+}
+
+func (enc *jsonEncoder) Encode(buf []byte, entry *Entry) []byte {
+	buf = append(buf, `{"time":`...)
+	buf = appendJSON(buf, entry.Time.Format(time.RFC3339Nano))
+	buf = append(buf, `,"level":`...)
+	buf = appendJSON(buf, levelNames[entry.Level])
+
+	if entry.Prefix != "" {
+		buf = append(buf, `,"logger":`...)
+		buf = appendJSON(buf, entry.Prefix)
+	}
+
+	if entry.File != "" {
+		buf = append(buf, `,"caller":`...)
+		buf = appendJSON(buf, entry.File+":"+strconv.Itoa(entry.Line))
+	}
+
+	buf = append(buf, `,"msg":`...)
+	buf = appendJSON(buf, strings.TrimSuffix(entry.Message, "\n"))
+
+	for _, field := range entry.Fields {
+		buf = append(buf, ',')
+		buf = appendJSON(buf, field.Key)
+		buf = append(buf, ':')
+
+		switch field.Type {
+		case IntType:
+			buf = strconv.AppendInt(buf, field.Integer, 10)
+		case UintType:
+			buf = strconv.AppendUint(buf, uint64(field.Integer), 10)
+		case BoolType:
+			buf = strconv.AppendBool(buf, field.Integer == 1)
+		case DurationType, TimeType:
+			buf = appendJSON(buf, field.text())
+		default:
+			buf = appendJSON(buf, field.Value())
+		}
+	}
+
+	return append(buf, '}', '\n')
+}
+
+// appendJSON appends value encoded as JSON, values which could not be encoded are written as strings
+func appendJSON(buf []byte, value interface{}) []byte {
+	data, err := json.Marshal(value)
+	if err != nil {
+		data, _ = json.Marshal(err.Error())
+	}
+	return append(buf, data...)
+}
@@ -0,0 +1,114 @@
+package log
+
+import (
+	"fmt"
+	"time"
+)
+
+// FieldType defines how value of field is stored and encoded
+type FieldType uint8
+
+const (
+	StringType FieldType = iota
+	IntType
+	UintType
+	FloatType
+	BoolType
+	DurationType
+	TimeType
+	ErrorType
+	AnyType
+)
+
+// Field is a typed key/value pair attached to log entries
+type Field struct {
+	Key     string
+	Type    FieldType
+	Integer int64
+	Float   float64
+	Str     string
+	Iface   interface{}
+}
+
+func String(key, value string) Field {
+	return Field{Key: key, Type: StringType, Str: value}
+}
+
+func Int(key string, value int) Field {
+	return Field{Key: key, Type: IntType, Integer: int64(value)}
+}
+
+func Int64(key string, value int64) Field {
+	return Field{Key: key, Type: IntType, Integer: value}
+}
+
+func Uint64(key string, value uint64) Field {
+	return Field{Key: key, Type: UintType, Integer: int64(value)}
+}
+
+func Float64(key string, value float64) Field {
+	return Field{Key: key, Type: FloatType, Float: value}
+}
+
+func Bool(key string, value bool) Field {
+	var i int64
+	if value {
+		i = 1
+	}
+	return Field{Key: key, Type: BoolType, Integer: i}
+}
+
+func Duration(key string, value time.Duration) Field {
+	return Field{Key: key, Type: DurationType, Integer: int64(value)}
+}
+
+func Time(key string, value time.Time) Field {
+	return Field{Key: key, Type: TimeType, Iface: value}
+}
+
+// Err returns field "error" with message of err
+func Err(err error) Field {
+	return Field{Key: "error", Type: ErrorType, Iface: err}
+}
+
+// Any returns field with arbitrary value, it is encoded as JSON by json encoder and with fmt otherwise
+func Any(key string, value interface{}) Field {
+	return Field{Key: key, Type: AnyType, Iface: value}
+}
+
+// Value returns value of field
+func (f Field) Value() interface{} {
+	switch f.Type {
+	case StringType:
+		return f.Str
+	case IntType:
+		return f.Integer
+	case UintType:
+		return uint64(f.Integer)
+	case FloatType:
+		return f.Float
+	case BoolType:
+		return f.Integer == 1
+	case DurationType:
+		return time.Duration(f.Integer)
+	case ErrorType:
+		if f.Iface == nil {
+			return nil
+		}
+		return f.Iface.(error).Error()
+	default:
+		return f.Iface
+	}
+}
+
+// text returns value of field formatted for humans
+func (f Field) text() string {
+	switch f.Type {
+	case StringType:
+		return f.Str
+	case TimeType:
+		return f.Iface.(time.Time).Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(f.Value())
+	}
+}
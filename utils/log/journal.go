@@ -0,0 +1,100 @@
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// DefaultJournalSocket is a socket of systemd-journald native protocol
+const DefaultJournalSocket = "/run/systemd/journal/socket"
+
+type JournalConfig struct {
+	Socket     string
+	Identifier string
+}
+
+// JournalWriter sends every written message to journald using its native protocol.
+// Messages are sent in a single datagram, so these are limited by the socket buffer size.
+type JournalWriter struct {
+	mu   sync.Mutex
+	cfg  JournalConfig
+	conn net.Conn
+}
+
+func NewJournalWriter(cfg *JournalConfig) (*JournalWriter, error) {
+	w := &JournalWriter{cfg: *cfg}
+	if w.cfg.Socket == "" {
+		w.cfg.Socket = DefaultJournalSocket
+	}
+
+	conn, err := net.Dial("unixgram", w.cfg.Socket)
+	if err != nil {
+		return nil, fmt.Errorf("error connect to journal %s: %s", w.cfg.Socket, err.Error())
+	}
+	w.conn = conn
+
+	return w, nil
+}
+
+// Write sends message of unknown level as INFO one
+func (w *JournalWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(INFO, p)
+}
+
+// WriteLevel sends p as MESSAGE field with PRIORITY of level
+func (w *JournalWriter) WriteLevel(level int, p []byte) (int, error) {
+	var buf bytes.Buffer
+	appendJournalField(&buf, "MESSAGE", bytes.TrimRight(p, "\n"))
+	appendJournalField(&buf, "PRIORITY", []byte(strconv.Itoa(syslogSeverity[level])))
+	if w.cfg.Identifier != "" {
+		appendJournalField(&buf, "SYSLOG_IDENTIFIER", []byte(w.cfg.Identifier))
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn == nil {
+		return 0, fmt.Errorf("journal writer is closed")
+	}
+
+	if _, err := w.conn.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// appendJournalField writes "NAME=value\n" or, if value contains newlines,
+// "NAME\n" followed by little endian 64 bit size of value, value and "\n"
+func appendJournalField(buf *bytes.Buffer, name string, value []byte) {
+	buf.WriteString(name)
+	if bytes.IndexByte(value, '\n') < 0 {
+		buf.WriteByte('=')
+		buf.Write(value)
+		buf.WriteByte('\n')
+		return
+	}
+
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+	buf.WriteByte('\n')
+	buf.Write(size[:])
+	buf.Write(value)
+	buf.WriteByte('\n')
+}
+
+func (w *JournalWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn == nil {
+		return nil
+	}
+
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
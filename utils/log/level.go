@@ -0,0 +1,137 @@
+package log
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const inherit = -1
+
+// Level is a verbosity level which could be changed while loggers use it.
+// Level created by Levels inherits the root level until it is set.
+type Level struct {
+	value  int32
+	parent *Level
+}
+
+func NewLevel(level int) *Level {
+	return &Level{value: int32(level)}
+}
+
+func (l *Level) Get() int {
+	if value := atomic.LoadInt32(&l.value); value != inherit || l.parent == nil {
+		return int(value)
+	}
+	return l.parent.Get()
+}
+
+func (l *Level) Set(level int) {
+	atomic.StoreInt32(&l.value, int32(level))
+}
+
+// Reset makes level inherit the parent level
+func (l *Level) Reset() {
+	if l.parent != nil {
+		atomic.StoreInt32(&l.value, inherit)
+	}
+}
+
+// Inherited returns whether level follows the parent level
+func (l *Level) Inherited() bool {
+	return l.parent != nil && atomic.LoadInt32(&l.value) == inherit
+}
+
+// ParseLevel returns level by its name, e.g. "debug" or "WARNING"
+func ParseLevel(name string) (int, error) {
+	for level, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q, known are: %s", name, strings.Join(levelNames[:], ", "))
+}
+
+func LevelName(level int) string {
+	if level < DEBUG || level > ERROR {
+		return "unknown"
+	}
+	return levelNames[level]
+}
+
+// Levels holds named levels (of handlers or components) inheriting the root level
+type Levels struct {
+	mu     sync.Mutex
+	root   *Level
+	levels map[string]*Level
+}
+
+func NewLevels(root int) *Levels {
+	return &Levels{
+		root:   NewLevel(root),
+		levels: make(map[string]*Level),
+	}
+}
+
+func (ls *Levels) Root() *Level {
+	return ls.root
+}
+
+// Level returns level of name registering it if it is unknown
+func (ls *Levels) Level(name string) *Level {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	level, ok := ls.levels[name]
+	if !ok {
+		level = &Level{value: inherit, parent: ls.root}
+		ls.levels[name] = level
+	}
+	return level
+}
+
+// Lookup returns level of registered name or root level if name is empty
+func (ls *Levels) Lookup(name string) (*Level, bool) {
+	if name == "" {
+		return ls.root, true
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	level, ok := ls.levels[name]
+	return level, ok
+}
+
+// NamedLevel is a level of registered name
+type NamedLevel struct {
+	Name      string `json:"name"`
+	Level     string `json:"level"`
+	Inherited bool   `json:"inherited,omitempty"`
+}
+
+// All returns root level (with empty name) followed by registered levels sorted by name
+func (ls *Levels) All() []NamedLevel {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	names := make([]string, 0, len(ls.levels))
+	for name := range ls.levels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := make([]NamedLevel, 0, len(names)+1)
+	result = append(result, NamedLevel{Level: LevelName(ls.root.Get())})
+	for _, name := range names {
+		level := ls.levels[name]
+		result = append(result, NamedLevel{
+			Name:      name,
+			Level:     LevelName(level.Get()),
+			Inherited: level.Inherited(),
+		})
+	}
+	return result
+}
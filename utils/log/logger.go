@@ -41,6 +41,8 @@ type ILogger interface {
 	Error(args ...interface{})
 	Errorf(format string, args ...interface{})
 	ErrorIfNotNil(message string, err error)
+	// With returns child logger adding fields to every entry
+	With(fields ...Field) ILogger
 }
 
 // A Logger represents an active logging object that generates lines of
@@ -48,10 +50,12 @@ type ILogger interface {
 // the Writer's Write method.  A Logger can be used simultaneously from
 // multiple goroutines; it guarantees to serialize access to the Writer.
 type logger struct {
-	prefix string    // prefix to write at beginning of each line
-	flag   int       // properties
-	level  int       // verbosity level
-	out    io.Writer // destination for output
+	prefix  string    // prefix to write at beginning of each line
+	flag    int       // properties
+	level   *Level    // verbosity level
+	out     io.Writer // destination for output
+	encoder IEncoder  // format of output
+	fields  []Field   // fields added to every entry
 }
 
 // New creates a new Logger.
@@ -59,14 +63,34 @@ type logger struct {
 // The prefix appears at the beginning of each generated log line.
 // The flag argument defines the logging properties.
 func New(out io.Writer, prefix string, level int) ILogger {
+	return NewWithEncoder(out, prefix, level, NewTextEncoder(LstdFlags, false))
+}
+
+// NewWithEncoder creates a new Logger writing entries in format of encoder.
+func NewWithEncoder(out io.Writer, prefix string, level int, encoder IEncoder) ILogger {
+	return NewWithLevel(out, prefix, NewLevel(level), encoder)
+}
+
+// NewWithLevel creates a new Logger which verbosity follows changes of level.
+func NewWithLevel(out io.Writer, prefix string, level *Level, encoder IEncoder) ILogger {
 	return &logger{
-		out:    out,
-		prefix: prefix,
-		flag:   LstdFlags,
-		level:  level,
+		out:     out,
+		prefix:  prefix,
+		flag:    LstdFlags,
+		level:   level,
+		encoder: encoder,
 	}
 }
 
+// With returns a child Logger which writes fields of the parent followed by the given fields.
+func (l *logger) With(fields ...Field) ILogger {
+	child := *l
+	child.fields = make([]Field, 0, len(l.fields)+len(fields))
+	child.fields = append(child.fields, l.fields...)
+	child.fields = append(child.fields, fields...)
+	return &child
+}
+
 // Cheap integer to fixed-width decimal ASCII.  Give a negative width to avoid zero-padding.
 func itoa(buf *[]byte, i int, wid int) {
 	// Assemble decimal in reverse order.
@@ -136,13 +160,11 @@ func formatHeader(buf *[]byte, t time.Time, file, prefix string, line, level, fl
 }
 
 // Output writes the output for a logging event.  The string s contains
-// the text to print after the prefix specified by the flags of the
-// Logger.  A newline is appended if the last character of s is not
-// already a newline.  Calldepth is used to recover the PC and is
-// provided for generality, although at the moment on all pre-defined
+// the message which is encoded with fields of the Logger by its encoder.
+// Calldepth is used to recover the PC and is provided for generality, although at the moment on all pre-defined
 // paths it will be 2.
 func (l *logger) output(calldepth, level int, format string, v ...interface{}) error {
-	if l.level > level {
+	if l.level.Get() > level {
 		return nil
 	}
 
@@ -166,14 +188,18 @@ func (l *logger) output(calldepth, level int, format string, v ...interface{}) e
 		s = fmt.Sprintf(format, v...)
 	}
 
-	buf := getBytes()
-	formatHeader(&buf, now, file, l.prefix, line, level, l.flag)
-	buf = append(buf, s...)
-	if len(s) > 0 && s[len(s)-1] != '\n' {
-		buf = append(buf, '\n')
+	entry := &Entry{
+		Time:    now,
+		Level:   level,
+		Prefix:  l.prefix,
+		File:    file,
+		Line:    line,
+		Message: s,
+		Fields:  l.fields,
 	}
 
-	_, err := l.out.Write(buf)
+	buf := l.encoder.Encode(getBytes(), entry)
+	_, err := write(l.out, entry, buf)
 
 	putBytes(buf)
 
@@ -0,0 +1,183 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/THE108/requestcounter/utils/tracedata"
+
+	"golang.org/x/net/context"
+	. "gopkg.in/check.v1"
+)
+
+type LoggerSuite struct{}
+
+var _ = Suite(&LoggerSuite{})
+
+// Hook up gocheck into the "go test" runner.
+func TestStart(t *testing.T) {
+	TestingT(t)
+}
+
+func (suite *LoggerSuite) TestText(c *C) {
+	var buf bytes.Buffer
+	logger := New(&buf, "Current", INFO).With(String("key", "a b"), Int("count", 3))
+
+	logger.Debug("skipped")
+	logger.With(Duration("wait", time.Second), Err(errors.New("failed"))).Warningf("waited %d times", 2)
+
+	line := buf.String()
+	c.Assert(strings.HasSuffix(line, ` Current [W] waited 2 times key="a b" count=3 wait=1s error=failed`+"\n"), Equals, true, Commentf(line))
+}
+
+func (suite *LoggerSuite) TestJSON(c *C) {
+	var buf bytes.Buffer
+	logger := NewWithEncoder(&buf, "Current", DEBUG, NewJSONEncoder())
+
+	logger.With(String("key", "value"), Uint64("size", 10), Bool("ok", true), Any("list", []int{1, 2})).Debug("message\n")
+
+	var entry map[string]interface{}
+	c.Assert(json.Unmarshal(buf.Bytes(), &entry), IsNil)
+	c.Assert(entry["level"], Equals, "debug")
+	c.Assert(entry["logger"], Equals, "Current")
+	c.Assert(entry["msg"], Equals, "message")
+	c.Assert(entry["key"], Equals, "value")
+	c.Assert(entry["size"], Equals, float64(10))
+	c.Assert(entry["ok"], Equals, true)
+	c.Assert(entry["list"], DeepEquals, []interface{}{float64(1), float64(2)})
+
+	_, err := time.Parse(time.RFC3339Nano, entry["time"].(string))
+	c.Assert(err, IsNil)
+}
+
+func (suite *LoggerSuite) TestWithDoesNotChangeParent(c *C) {
+	var buf bytes.Buffer
+	parent := NewWithEncoder(&buf, "", INFO, NewJSONEncoder()).With(String("a", "1"))
+	parent.With(String("b", "2"))
+	parent.With(String("c", "3")).Info("child")
+	parent.Info("parent")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	c.Assert(lines, HasLen, 2)
+	c.Assert(strings.HasSuffix(lines[0], `"msg":"child","a":"1","c":"3"}`), Equals, true, Commentf(lines[0]))
+	c.Assert(strings.HasSuffix(lines[1], `"msg":"parent","a":"1"}`), Equals, true, Commentf(lines[1]))
+}
+
+func (suite *LoggerSuite) TestTraceDataFromContext(c *C) {
+	var buf bytes.Buffer
+	ctx := tracedata.SetTraceDataToContext(context.Background(), &tracedata.TraceData{TraceID: "T", SpanID: "S"})
+	ctx = SetLoggerToContext(ctx, NewWithEncoder(&buf, "", INFO, NewJSONEncoder()))
+
+	GetLoggerFromContext(ctx).Info("traced")
+
+	c.Assert(strings.HasSuffix(buf.String(), `"msg":"traced","trace_id":"T","span_id":"S"}`+"\n"), Equals, true, Commentf(buf.String()))
+}
+
+func (suite *LoggerSuite) TestTracePrefix(c *C) {
+	var buf bytes.Buffer
+	ctx := tracedata.SetTraceDataToContext(context.Background(), &tracedata.TraceData{TraceID: "T", SpanID: "S", ParentSpanID: "P"})
+	ctx = SetLoggerToContext(ctx, NewWithEncoder(&buf, "GetRequestCount", INFO, NewTextEncoder(0, false)))
+
+	GetLoggerFromContext(ctx).With(Int("count", 1)).Info("traced")
+	c.Assert(buf.String(), Equals, "T|S|P [I] traced count=1\n")
+
+	buf.Reset()
+	ctx = SetLoggerToContext(ctx, NewWithEncoder(&buf, "GetRequestCount", INFO, NewTextEncoder(0, true)))
+	GetLoggerFromContext(ctx).Info("traced")
+	c.Assert(buf.String(), Equals, "GetRequestCount [I] traced trace_id=T span_id=S parent_span_id=P\n")
+}
+
+func (suite *LoggerSuite) TestLevels(c *C) {
+	levels := NewLevels(INFO)
+	levels.Level("Current").Set(DEBUG)
+
+	var buf bytes.Buffer
+	current := NewWithLevel(&buf, "Current", levels.Level("Current"), NewTextEncoder(0, true))
+	history := NewWithLevel(&buf, "History", levels.Level("History"), NewTextEncoder(0, true))
+
+	current.Debug("current")
+	history.Debug("history skipped")
+	c.Assert(buf.String(), Equals, "Current [D] current\n")
+
+	// live loggers follow changes of levels
+	levels.Root().Set(DEBUG)
+	history.Debug("history")
+	levels.Level("Current").Set(ERROR)
+	current.Info("current skipped")
+	c.Assert(buf.String(), Equals, "Current [D] current\nHistory [D] history\n")
+
+	level, ok := levels.Lookup("Current")
+	c.Assert(ok, Equals, true)
+	level.Reset()
+	c.Assert(level.Get(), Equals, DEBUG)
+
+	_, ok = levels.Lookup("Unknown")
+	c.Assert(ok, Equals, false)
+
+	c.Assert(levels.All(), DeepEquals, []NamedLevel{
+		{Level: "debug"},
+		{Name: "Current", Level: "debug", Inherited: true},
+		{Name: "History", Level: "debug", Inherited: true},
+	})
+
+	parsed, err := ParseLevel("WARNING")
+	c.Assert(err, IsNil)
+	c.Assert(parsed, Equals, WARNING)
+
+	_, err = ParseLevel("verbose")
+	c.Assert(err, NotNil)
+}
+
+func (suite *LoggerSuite) TestRing(c *C) {
+	var buf bytes.Buffer
+	ring := NewRing(3, 24)
+	logger := NewWithEncoder(NewTee(&buf, ring), "", DEBUG, NewTextEncoder(0, true))
+
+	start := time.Now()
+	logger.Debug("first")
+	logger.With(String("trace_id", "T1")).Warning("second")
+	logger.With(String("trace_id", "T2")).Error("third, which is too long")
+	logger.With(String("trace_id", "T1")).Info("fourth")
+
+	// the ring keeps last entries only, the output gets all of them
+	c.Assert(strings.Count(buf.String(), "\n"), Equals, 4)
+
+	lines := func(entries []RingEntry) []string {
+		var result []string
+		for _, entry := range entries {
+			result = append(result, entry.Line)
+		}
+		return result
+	}
+
+	c.Assert(lines(ring.Entries(&RingFilter{})), DeepEquals, []string{
+		"[W] second trace_id=T1\n",
+		"[E] third, which is too ",
+		"[I] fourth trace_id=T1\n",
+	})
+
+	c.Assert(lines(ring.Entries(&RingFilter{Level: WARNING})), DeepEquals, []string{
+		"[W] second trace_id=T1\n",
+		"[E] third, which is too ",
+	})
+
+	c.Assert(lines(ring.Entries(&RingFilter{TraceID: "T1", Limit: 1})), DeepEquals, []string{
+		"[I] fourth trace_id=T1\n",
+	})
+
+	// buffers of entries are not larger than max entry size
+	for _, entry := range ring.entries {
+		c.Assert(cap(entry.data), Equals, 24)
+	}
+
+	c.Assert(ring.Entries(&RingFilter{From: start.Add(time.Hour)}), HasLen, 0)
+	c.Assert(ring.Entries(&RingFilter{To: start.Add(time.Hour)}), HasLen, 3)
+
+	entry := ring.Entries(&RingFilter{Limit: 1})[0]
+	c.Assert(entry.Level, Equals, "info")
+	c.Assert(entry.TraceID, Equals, "T1")
+}
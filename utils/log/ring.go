@@ -0,0 +1,174 @@
+package log
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// IEntryWriter defines outputs which handle entries, p is the encoded entry
+type IEntryWriter interface {
+	WriteEntry(entry *Entry, p []byte) (int, error)
+}
+
+// write passes entry or its level to the output if it handles them
+func write(out io.Writer, entry *Entry, p []byte) (int, error) {
+	if ew, ok := out.(IEntryWriter); ok {
+		return ew.WriteEntry(entry, p)
+	}
+	if lw, ok := out.(ILevelWriter); ok {
+		return lw.WriteLevel(entry.Level, p)
+	}
+	return out.Write(p)
+}
+
+type tee struct {
+	outputs []io.Writer
+}
+
+// NewTee returns output writing to all outputs, errors of all but the first output are ignored
+func NewTee(outputs ...io.Writer) io.Writer {
+	return &tee{outputs: outputs}
+}
+
+func (t *tee) Write(p []byte) (int, error) {
+	return t.WriteLevel(INFO, p)
+}
+
+func (t *tee) WriteLevel(level int, p []byte) (int, error) {
+	return t.WriteEntry(&Entry{Time: time.Now(), Level: level}, p)
+}
+
+func (t *tee) WriteEntry(entry *Entry, p []byte) (int, error) {
+	n, err := write(t.outputs[0], entry, p)
+	for _, out := range t.outputs[1:] {
+		write(out, entry, p)
+	}
+	return n, err
+}
+
+type ringEntry struct {
+	time    time.Time
+	level   int
+	logger  string
+	traceID string
+	data    []byte
+}
+
+// Ring keeps the last entries in memory, every entry is truncated to max entry size
+type Ring struct {
+	mu           sync.Mutex
+	entries      []ringEntry
+	next         int
+	maxEntrySize int
+}
+
+func NewRing(size, maxEntrySize int) *Ring {
+	return &Ring{
+		entries:      make([]ringEntry, 0, size),
+		maxEntrySize: maxEntrySize,
+	}
+}
+
+func (r *Ring) Write(p []byte) (int, error) {
+	return r.WriteLevel(INFO, p)
+}
+
+func (r *Ring) WriteLevel(level int, p []byte) (int, error) {
+	return r.WriteEntry(&Entry{Time: time.Now(), Level: level}, p)
+}
+
+// WriteEntry replaces the oldest entry if the ring is full reusing its buffer.
+// Buffers are allocated with max entry size capacity so memory is bounded by size * max entry size.
+func (r *Ring) WriteEntry(entry *Entry, p []byte) (int, error) {
+	if cap(r.entries) == 0 {
+		return len(p), nil
+	}
+
+	data := p
+	if len(data) > r.maxEntrySize {
+		data = data[:r.maxEntrySize]
+	}
+
+	var traceID string
+	for _, field := range entry.Fields {
+		if field.Key == TraceIDKey {
+			traceID = field.Str
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var buf []byte
+	if len(r.entries) < cap(r.entries) {
+		r.entries = append(r.entries, ringEntry{})
+		buf = make([]byte, 0, r.maxEntrySize)
+	} else {
+		buf = r.entries[r.next].data[:0]
+	}
+
+	r.entries[r.next] = ringEntry{
+		time:    entry.Time,
+		level:   entry.Level,
+		logger:  entry.Prefix,
+		traceID: traceID,
+		data:    append(buf, data...),
+	}
+	r.next = (r.next + 1) % cap(r.entries)
+
+	return len(p), nil
+}
+
+// RingFilter selects entries with at least Level, TraceID if set and time in [From, To] if set.
+// At most Limit latest entries are selected if Limit is positive.
+type RingFilter struct {
+	Level   int
+	TraceID string
+	From    time.Time
+	To      time.Time
+	Limit   int
+}
+
+type RingEntry struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Logger  string    `json:"logger,omitempty"`
+	TraceID string    `json:"trace_id,omitempty"`
+	Line    string    `json:"line"`
+}
+
+// Entries returns entries matching filter from the oldest to the latest
+func (r *Ring) Entries(filter *RingFilter) []RingEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := []RingEntry{}
+	// the latest entries are selected first, so iterate backwards
+	for i := 0; i < len(r.entries); i++ {
+		if filter.Limit > 0 && len(result) >= filter.Limit {
+			break
+		}
+
+		entry := &r.entries[(r.next-1-i+2*len(r.entries))%len(r.entries)]
+		if entry.level < filter.Level ||
+			(filter.TraceID != "" && entry.traceID != filter.TraceID) ||
+			(!filter.From.IsZero() && entry.time.Before(filter.From)) ||
+			(!filter.To.IsZero() && entry.time.After(filter.To)) {
+			continue
+		}
+
+		result = append(result, RingEntry{
+			Time:    entry.time,
+			Level:   LevelName(entry.level),
+			Logger:  entry.logger,
+			TraceID: entry.traceID,
+			Line:    string(entry.data),
+		})
+	}
+
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result
+}
@@ -0,0 +1,88 @@
+package log
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func listenUnixgram(c *C, name string) (*net.UnixConn, string, func()) {
+	dir, err := ioutil.TempDir("", "logsink")
+	c.Assert(err, IsNil)
+
+	path := filepath.Join(dir, name)
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	c.Assert(err, IsNil)
+
+	return conn, path, func() {
+		conn.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func receive(c *C, conn net.PacketConn) string {
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	c.Assert(err, IsNil)
+	return string(buf[:n])
+}
+
+func (suite *LoggerSuite) TestSyslogUnixgram(c *C) {
+	conn, path, cleanup := listenUnixgram(c, "log")
+	defer cleanup()
+
+	w, err := NewSyslogWriter(&SyslogConfig{Network: "unixgram", Address: path, Facility: 16, Tag: "requestcounter"})
+	c.Assert(err, IsNil)
+	defer w.Close()
+
+	logger := NewWithEncoder(w, "Current", DEBUG, NewJSONEncoder())
+	logger.Warning("slow")
+	logger.Debug("details")
+
+	// local0 (16) * 8 + warning (4) and + debug (7)
+	pattern := `^<%s>1 \d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{6}\S+ \S+ requestcounter \d+ - - \{.*"msg":"%s"\}$`
+	c.Assert(receive(c, conn), Matches, fmt.Sprintf(pattern, "132", "slow"))
+	c.Assert(receive(c, conn), Matches, fmt.Sprintf(pattern, "135", "details"))
+}
+
+func (suite *LoggerSuite) TestSyslogUDP(c *C) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	defer conn.Close()
+
+	w, err := NewSyslogWriter(&SyslogConfig{Network: "udp", Address: conn.LocalAddr().String(), Facility: 3, Tag: "rc"})
+	c.Assert(err, IsNil)
+	defer w.Close()
+
+	w.WriteLevel(ERROR, []byte("failed\n"))
+
+	c.Assert(receive(c, conn), Matches, `<27>1 \S+ \S+ rc \d+ - - failed`)
+
+	_, err = ParseFacility("local9")
+	c.Assert(err, NotNil)
+}
+
+func (suite *LoggerSuite) TestJournal(c *C) {
+	conn, path, cleanup := listenUnixgram(c, "socket")
+	defer cleanup()
+
+	w, err := NewJournalWriter(&JournalConfig{Socket: path, Identifier: "requestcounter"})
+	c.Assert(err, IsNil)
+	defer w.Close()
+
+	w.WriteLevel(INFO, []byte("started\n"))
+	c.Assert(receive(c, conn), Equals, "MESSAGE=started\nPRIORITY=6\nSYSLOG_IDENTIFIER=requestcounter\n")
+
+	w.WriteLevel(ERROR, []byte("panic\nstack\n"))
+
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len("panic\nstack")))
+	c.Assert(receive(c, conn), Equals, "MESSAGE\n"+string(size[:])+"panic\nstack\nPRIORITY=3\nSYSLOG_IDENTIFIER=requestcounter\n")
+}
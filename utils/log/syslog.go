@@ -0,0 +1,141 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// syslogSeverity maps levels to syslog severities (RFC 5424)
+var syslogSeverity = [4]int{
+	DEBUG:   7, // debug
+	INFO:    6, // informational
+	WARNING: 4, // warning
+	ERROR:   3, // error
+}
+
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// ParseFacility returns syslog facility by its name, e.g. "daemon" or "local0"
+func ParseFacility(name string) (int, error) {
+	facility, ok := syslogFacilities[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown syslog facility %q", name)
+	}
+	return facility, nil
+}
+
+type SyslogConfig struct {
+	// Network is "unixgram" for a local socket (e.g. /dev/log) or "udp"
+	Network  string
+	Address  string
+	Facility int
+	// Tag is APP-NAME of messages
+	Tag string
+}
+
+// SyslogWriter sends every written message as RFC 5424 syslog message in a datagram
+type SyslogWriter struct {
+	mu       sync.Mutex
+	cfg      SyslogConfig
+	hostname string
+	pid      string
+	conn     net.Conn
+}
+
+func NewSyslogWriter(cfg *SyslogConfig) (*SyslogWriter, error) {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	w := &SyslogWriter{
+		cfg:      *cfg,
+		hostname: hostname,
+		pid:      strconv.Itoa(os.Getpid()),
+	}
+
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *SyslogWriter) connect() error {
+	conn, err := net.Dial(w.cfg.Network, w.cfg.Address)
+	if err != nil {
+		return fmt.Errorf("error connect to syslog %s %s: %s", w.cfg.Network, w.cfg.Address, err.Error())
+	}
+	w.conn = conn
+	return nil
+}
+
+// Write sends message of unknown level as INFO one
+func (w *SyslogWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(INFO, p)
+}
+
+// WriteLevel sends p with severity of level, the connection is reestablished once if sending fails
+func (w *SyslogWriter) WriteLevel(level int, p []byte) (int, error) {
+	msg := w.format(level, time.Now(), p)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn != nil {
+		if _, err := w.conn.Write(msg); err == nil {
+			return len(p), nil
+		}
+		w.conn.Close()
+		w.conn = nil
+	}
+
+	if err := w.connect(); err != nil {
+		return 0, err
+	}
+
+	if _, err := w.conn.Write(msg); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// format returns message "<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG"
+func (w *SyslogWriter) format(level int, t time.Time, p []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte('<')
+	buf.WriteString(strconv.Itoa(w.cfg.Facility*8 + syslogSeverity[level]))
+	buf.WriteString(">1 ")
+	buf.WriteString(t.Format("2006-01-02T15:04:05.000000Z07:00"))
+	buf.WriteByte(' ')
+	buf.WriteString(w.hostname)
+	buf.WriteByte(' ')
+	buf.WriteString(w.cfg.Tag)
+	buf.WriteByte(' ')
+	buf.WriteString(w.pid)
+	buf.WriteString(" - - ")
+	buf.Write(bytes.TrimRight(p, "\n"))
+	return buf.Bytes()
+}
+
+func (w *SyslogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn == nil {
+		return nil
+	}
+
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
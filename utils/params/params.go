@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -35,12 +37,7 @@ func (params *Params) String(key string, required bool, defaultValue ...string)
 		defaultVal = defaultValue[0]
 	}
 
-	vars := mux.Vars(params.Request)
-	if vars == nil {
-		return defaultVal, getErrorIfRequiredMissed(key, required)
-	}
-
-	if value, ok := vars[key]; ok {
+	if value, ok := mux.Vars(params.Request)[key]; ok {
 		return value, nil
 	}
 
@@ -59,7 +56,7 @@ func (params *Params) Bool(key string, required bool, defaultValue ...bool) (boo
 	}
 
 	strVal, err := params.String(key, required)
-	if err != nil {
+	if err != nil || strVal == "" {
 		return defaultVal, err
 	}
 
@@ -79,7 +76,7 @@ func (params *Params) Uint64(key string, required bool, defaultValue ...uint64)
 	}
 
 	strVal, err := params.String(key, required)
-	if err != nil {
+	if err != nil || strVal == "" {
 		return defaultVal, err
 	}
 
@@ -90,3 +87,86 @@ func (params *Params) Uint64(key string, required bool, defaultValue ...uint64)
 
 	return value, nil
 }
+
+// Duration returns time.Duration-value from query string
+func (params *Params) Duration(key string, required bool, defaultValue ...time.Duration) (time.Duration, error) {
+	var defaultVal time.Duration
+	if len(defaultValue) > 0 {
+		defaultVal = defaultValue[0]
+	}
+
+	strVal, err := params.String(key, required)
+	if err != nil || strVal == "" {
+		return defaultVal, err
+	}
+
+	value, err := time.ParseDuration(strVal)
+	if err != nil {
+		return defaultVal, fmt.Errorf("invalid duration %s specified:%q error:%s", key, strVal, err)
+	}
+
+	return value, nil
+}
+
+// Time returns time.Time-value from query string.
+// Value could be either RFC3339 formatted or unix timestamp in seconds
+func (params *Params) Time(key string, required bool, defaultValue ...time.Time) (time.Time, error) {
+	var defaultVal time.Time
+	if len(defaultValue) > 0 {
+		defaultVal = defaultValue[0]
+	}
+
+	strVal, err := params.String(key, required)
+	if err != nil || strVal == "" {
+		return defaultVal, err
+	}
+
+	if seconds, err := strconv.ParseInt(strVal, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+
+	value, err := time.Parse(time.RFC3339, strVal)
+	if err != nil {
+		return defaultVal, fmt.Errorf("invalid time %s specified:%q error:%s", key, strVal, err)
+	}
+
+	return value, nil
+}
+
+// Strings returns all values of param from query string.
+// Comma separated values are split
+func (params *Params) Strings(key string, required bool) ([]string, error) {
+	var result []string
+	for _, value := range params.Request.URL.Query()[key] {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				result = append(result, item)
+			}
+		}
+	}
+
+	if len(result) == 0 {
+		return nil, getErrorIfRequiredMissed(key, required)
+	}
+
+	return result, nil
+}
+
+// Labels returns label name-value pairs given as name:value from query string
+func (params *Params) Labels(key string, required bool) (map[string]string, error) {
+	values, err := params.Strings(key, required)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string, len(values))
+	for _, value := range values {
+		i := strings.IndexByte(value, ':')
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid label %s specified:%q expected name:value", key, value)
+		}
+		result[value[:i]] = value[i+1:]
+	}
+
+	return result, nil
+}
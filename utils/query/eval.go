@@ -0,0 +1,131 @@
+package query
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/THE108/requestcounter/utils/errors"
+)
+
+// ISource provides data of series to evaluate expressions on
+type ISource interface {
+	// Sum returns total of series name having labels of matchers during window.
+	// Zero window means the whole time period of the source.
+	Sum(name string, matchers map[string]string, window time.Duration) (float64, error)
+
+	// Period returns the whole time period of the source
+	Period() time.Duration
+}
+
+// Expr is a parsed expression
+type Expr struct {
+	root node
+}
+
+// Eval evaluates expression over data of src.
+// Division by zero evaluates to zero so ratios of idle series are not failed.
+func (e *Expr) Eval(src ISource) (float64, error) {
+	return e.root.eval(src)
+}
+
+type function func(src ISource, selector *selectorNode) (float64, error)
+
+var functions = map[string]function{
+	// rate returns per second average rate of series during range
+	"rate": func(src ISource, selector *selectorNode) (float64, error) {
+		sum, err := selector.eval(src)
+		if err != nil {
+			return 0, err
+		}
+
+		window := selector.window
+		if window == 0 {
+			window = src.Period()
+		}
+
+		if window <= 0 {
+			return 0, nil
+		}
+
+		return sum / window.Seconds(), nil
+	},
+
+	// increase returns total of series during range
+	"increase": func(src ISource, selector *selectorNode) (float64, error) {
+		return selector.eval(src)
+	},
+}
+
+type node interface {
+	eval(src ISource) (float64, error)
+}
+
+type numberNode struct {
+	value float64
+}
+
+func (n *numberNode) eval(src ISource) (float64, error) {
+	return n.value, nil
+}
+
+type negNode struct {
+	expr node
+}
+
+func (n *negNode) eval(src ISource) (float64, error) {
+	value, err := n.expr.eval(src)
+	return -value, err
+}
+
+type binaryNode struct {
+	op    byte
+	left  node
+	right node
+}
+
+func (n *binaryNode) eval(src ISource) (float64, error) {
+	left, err := n.left.eval(src)
+	if err != nil {
+		return 0, err
+	}
+
+	right, err := n.right.eval(src)
+	if err != nil {
+		return 0, err
+	}
+
+	switch n.op {
+	case '+':
+		return left + right, nil
+	case '-':
+		return left - right, nil
+	case '*':
+		return left * right, nil
+	case '/':
+		if right == 0 {
+			return 0, nil
+		}
+		return left / right, nil
+	}
+
+	return 0, errors.New(http.StatusInternalServerError, "unknown operator "+string(n.op))
+}
+
+type selectorNode struct {
+	name     string
+	matchers map[string]string
+	window   time.Duration
+}
+
+func (n *selectorNode) eval(src ISource) (float64, error) {
+	return src.Sum(n.name, n.matchers, n.window)
+}
+
+type callNode struct {
+	fn  function
+	arg *selectorNode
+}
+
+func (n *callNode) eval(src ISource) (float64, error) {
+	return n.fn(src, n.arg)
+}
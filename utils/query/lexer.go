@@ -0,0 +1,130 @@
+package query
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/THE108/requestcounter/utils/errors"
+)
+
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenNumber
+	tokenIdent
+	tokenString
+	tokenDuration
+	tokenPunct
+)
+
+type token struct {
+	typ  tokenType
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.typ {
+	case tokenEOF:
+		return "end of query"
+	case tokenDuration:
+		return "[" + t.text + "]"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// syntaxError returns error with position (zero based offset in query) of the mistake,
+// the position is also rendered in details of the error
+func syntaxError(pos int, format string, args ...interface{}) error {
+	return errors.New(http.StatusBadRequest,
+		fmt.Sprintf("syntax error at position %d: %s", pos, fmt.Sprintf(format, args...))).
+		WithReason("syntax_error").
+		WithDetail("position", pos)
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || isDigit(c) || c == ':'
+}
+
+// lex splits query into tokens
+func lex(q string) ([]token, error) {
+	var tokens []token
+	pos := 0
+	for pos < len(q) {
+		c := q[pos]
+		start := pos
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			pos++
+			continue
+
+		case isDigit(c) || c == '.':
+			for pos < len(q) && (isDigit(q[pos]) || q[pos] == '.') {
+				pos++
+			}
+			if pos < len(q) && (q[pos] == 'e' || q[pos] == 'E') {
+				pos++
+				if pos < len(q) && (q[pos] == '+' || q[pos] == '-') {
+					pos++
+				}
+				for pos < len(q) && isDigit(q[pos]) {
+					pos++
+				}
+			}
+			tokens = append(tokens, token{tokenNumber, q[start:pos], start})
+
+		case isIdentStart(c):
+			for pos < len(q) && isIdentChar(q[pos]) {
+				pos++
+			}
+			tokens = append(tokens, token{tokenIdent, q[start:pos], start})
+
+		case c == '"':
+			var buf []byte
+			pos++
+			for {
+				if pos >= len(q) {
+					return nil, syntaxError(start, "unterminated string")
+				}
+				if q[pos] == '"' {
+					pos++
+					break
+				}
+				if q[pos] == '\\' && pos+1 < len(q) {
+					pos++
+				}
+				buf = append(buf, q[pos])
+				pos++
+			}
+			tokens = append(tokens, token{tokenString, string(buf), start})
+
+		case c == '[':
+			end := strings.IndexByte(q[pos:], ']')
+			if end < 0 {
+				return nil, syntaxError(start, "unterminated range")
+			}
+			pos += end + 1
+			tokens = append(tokens, token{tokenDuration, strings.TrimSpace(q[start+1 : pos-1]), start})
+
+		case strings.IndexByte("+-*/(){},=", c) >= 0:
+			pos++
+			tokens = append(tokens, token{tokenPunct, q[start:pos], start})
+
+		default:
+			return nil, syntaxError(start, "unexpected character %q", c)
+		}
+	}
+
+	return append(tokens, token{tokenEOF, "", len(q)}), nil
+}
@@ -0,0 +1,222 @@
+package query
+
+import (
+	"strconv"
+	"time"
+)
+
+// Grammar:
+//
+//	expr     = term { ("+" | "-") term }
+//	term     = unary { ("*" | "/") unary }
+//	unary    = "-" unary | primary
+//	primary  = number | "(" expr ")" | ident "(" selector ")" | selector
+//	selector = ident [ "{" matcher { "," matcher } "}" ] [ "[" duration "]" ]
+//	matcher  = ident "=" ( string | ident )
+//
+// Supported functions are listed in functions.
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// Parse parses query into expression which could be evaluated multiple times
+func Parse(q string) (*Expr, error) {
+	tokens, err := lex(q)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.typ != tokenEOF {
+		return nil, syntaxError(t.pos, "unexpected %s", t)
+	}
+
+	return &Expr{root: root}, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.typ != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isPunct(text string) bool {
+	t := p.peek()
+	return t.typ == tokenPunct && t.text == text
+}
+
+func (p *parser) expectPunct(text string) error {
+	t := p.next()
+	if t.typ != tokenPunct || t.text != text {
+		return syntaxError(t.pos, "expected %q, got %s", text, t)
+	}
+	return nil
+}
+
+func (p *parser) parseExpr() (node, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isPunct("+") || p.isPunct("-") {
+		op := p.next().text[0]
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseTerm() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isPunct("*") || p.isPunct("/") {
+		op := p.next().text[0]
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.isPunct("-") {
+		p.next()
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &negNode{expr: expr}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.peek()
+	switch {
+	case t.typ == tokenNumber:
+		p.next()
+		value, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, syntaxError(t.pos, "invalid number %s", t)
+		}
+		return &numberNode{value: value}, nil
+
+	case t.typ == tokenPunct && t.text == "(":
+		p.next()
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectPunct(")"); err != nil {
+			return nil, err
+		}
+		return expr, nil
+
+	case t.typ == tokenIdent:
+		p.next()
+		if p.isPunct("(") {
+			return p.parseCall(t)
+		}
+		return p.parseSelector(t)
+	}
+
+	return nil, syntaxError(t.pos, "unexpected %s", t)
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	fn, ok := functions[name.text]
+	if !ok {
+		return nil, syntaxError(name.pos, "unknown function %s", name)
+	}
+
+	p.next() // (
+
+	t := p.next()
+	if t.typ != tokenIdent {
+		return nil, syntaxError(t.pos, "expected series selector, got %s", t)
+	}
+
+	arg, err := p.parseSelector(t)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expectPunct(")"); err != nil {
+		return nil, err
+	}
+
+	return &callNode{fn: fn, arg: arg}, nil
+}
+
+func (p *parser) parseSelector(name token) (*selectorNode, error) {
+	selector := &selectorNode{name: name.text}
+
+	if p.isPunct("{") {
+		p.next()
+		selector.matchers = make(map[string]string)
+		for !p.isPunct("}") {
+			if len(selector.matchers) > 0 {
+				if err := p.expectPunct(","); err != nil {
+					return nil, err
+				}
+			}
+
+			label := p.next()
+			if label.typ != tokenIdent {
+				return nil, syntaxError(label.pos, "expected label name, got %s", label)
+			}
+
+			if err := p.expectPunct("="); err != nil {
+				return nil, err
+			}
+
+			value := p.next()
+			if value.typ != tokenString && value.typ != tokenIdent {
+				return nil, syntaxError(value.pos, "expected label value, got %s", value)
+			}
+
+			if _, ok := selector.matchers[label.text]; ok {
+				return nil, syntaxError(label.pos, "duplicate label %s", label)
+			}
+			selector.matchers[label.text] = value.text
+		}
+		p.next() // }
+	}
+
+	if t := p.peek(); t.typ == tokenDuration {
+		p.next()
+		window, err := time.ParseDuration(t.text)
+		if err != nil || window <= 0 {
+			return nil, syntaxError(t.pos, "invalid range %s", t)
+		}
+		selector.window = window
+	}
+
+	return selector, nil
+}
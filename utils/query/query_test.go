@@ -0,0 +1,142 @@
+package query
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/THE108/requestcounter/utils/errors"
+
+	. "gopkg.in/check.v1"
+)
+
+type QuerySuite struct{}
+
+var _ = Suite(&QuerySuite{})
+
+// Hook up gocheck into the "go test" runner.
+func TestStart(t *testing.T) {
+	TestingT(t)
+}
+
+// fakeSource returns sums by selector key like name{label=value}[window]
+type fakeSource map[string]float64
+
+func (src fakeSource) Sum(name string, matchers map[string]string, window time.Duration) (float64, error) {
+	var pairs []string
+	for label, value := range matchers {
+		pairs = append(pairs, label+"="+value)
+	}
+	sort.Strings(pairs)
+
+	key := name
+	if len(pairs) > 0 {
+		key += "{" + strings.Join(pairs, ",") + "}"
+	}
+	if window > 0 {
+		key += "[" + window.String() + "]"
+	}
+
+	if name == "broken" {
+		return 0, fmt.Errorf("broken series")
+	}
+
+	return src[key], nil
+}
+
+func (src fakeSource) Period() time.Duration {
+	return time.Minute
+}
+
+var source = fakeSource{
+	"total":                          200,
+	"errors":                         10,
+	"responses{status=5xx}":          4,
+	"responses{route=/a,status=5xx}": 3,
+	"api[1m0s]":                      600,
+	"api[5m0s]":                      1500,
+	"api":                            120,
+}
+
+func (suite *QuerySuite) Test_Eval(c *C) {
+	for _, tc := range []struct {
+		query string
+		value float64
+	}{
+		{"42", 42},
+		{"1.5e2", 150},
+		{"-2", -2},
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"12 / 4 / 3", 1},
+		{"--3", 3},
+		{"errors / total", 0.05},
+		{"errors / unknown", 0},
+		{`responses{status="5xx"}`, 4},
+		{`responses{ status = "5xx" , route = "/a" }`, 3},
+		{`responses{route="/a",status="5xx"} / responses{status="5xx"}`, 0.75},
+		{"increase(api[5m])", 1500},
+		{"rate(api[1m]) - rate(api[5m])", 5},
+		{"rate(api)", 2},
+		{"rate(unknown[1m])", 0},
+		{"100 * errors / total", 5},
+	} {
+		expr, err := Parse(tc.query)
+		c.Assert(err, IsNil, Commentf("query: %s", tc.query))
+
+		value, err := expr.Eval(source)
+		c.Assert(err, IsNil, Commentf("query: %s", tc.query))
+		c.Assert(value, Equals, tc.value, Commentf("query: %s", tc.query))
+	}
+}
+
+func (suite *QuerySuite) Test_SyntaxErrors(c *C) {
+	for _, tc := range []struct {
+		query   string
+		message string
+	}{
+		{"", `syntax error at position 0: unexpected end of query`},
+		{"1 +", `syntax error at position 3: unexpected end of query`},
+		{"(1 + 2", `syntax error at position 6: expected ")", got end of query`},
+		{"1 2", `syntax error at position 2: unexpected "2"`},
+		{"errors # total", `syntax error at position 7: unexpected character '#'`},
+		{"1..2", `syntax error at position 0: invalid number "1..2"`},
+		{`responses{status="5xx}`, `syntax error at position 17: unterminated string`},
+		{`responses{status}`, `syntax error at position 16: expected "=", got "}"`},
+		{`responses{status=}`, `syntax error at position 17: expected label value, got "}"`},
+		{`responses{"status"="5xx"}`, `syntax error at position 10: expected label name, got "status"`},
+		{`responses{a="1" b="2"}`, `syntax error at position 16: expected ",", got "b"`},
+		{`responses{a="1",a="2"}`, `syntax error at position 16: duplicate label "a"`},
+		{"api[1m", `syntax error at position 3: unterminated range`},
+		{"api[1x]", `syntax error at position 3: invalid range [1x]`},
+		{"api[-1m]", `syntax error at position 3: invalid range [-1m]`},
+		{"avg(api)", `syntax error at position 0: unknown function "avg"`},
+		{"rate(1)", `syntax error at position 5: expected series selector, got "1"`},
+		{"rate(api[1m] + 1)", `syntax error at position 13: expected ")", got "+"`},
+	} {
+		_, err := Parse(tc.query)
+		c.Assert(err, NotNil, Commentf("query: %s", tc.query))
+		c.Assert(err.Error(), Equals, tc.message, Commentf("query: %s", tc.query))
+
+		codedErr, ok := err.(*errors.CodedError)
+		c.Assert(ok, Equals, true, Commentf("query: %s", tc.query))
+		c.Assert(codedErr.GetHttpCode(), Equals, http.StatusBadRequest)
+		c.Assert(codedErr.GetReason(), Equals, "syntax_error")
+
+		var pos int
+		fmt.Sscanf(tc.message, "syntax error at position %d:", &pos)
+		c.Assert(codedErr.Details["position"], Equals, pos, Commentf("query: %s", tc.query))
+	}
+}
+
+func (suite *QuerySuite) Test_EvalError(c *C) {
+	expr, err := Parse("errors / broken")
+	c.Assert(err, IsNil)
+
+	_, err = expr.Eval(source)
+	c.Assert(err, ErrorMatches, "broken series")
+}
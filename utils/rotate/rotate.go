@@ -0,0 +1,251 @@
+package rotate
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	compressSuffix = ".gz"
+	rotatingSuffix = ".rotating"
+
+	// rotateRetryInterval is a delay before the next rotation after a failed one
+	rotateRetryInterval = time.Minute
+)
+
+type Config struct {
+	Filename string
+	// MaxSize is a size in bytes after which the file is rotated, zero disables rotation by size
+	MaxSize int64
+	// MaxAge is a duration since the file is opened after which it is rotated, zero disables rotation by age
+	MaxAge time.Duration
+	// MaxBackups is a count of rotated files kept as filename.1 (the newest) ... filename.N
+	MaxBackups int
+	// Compress makes rotated files gzipped in background as filename.N.gz
+	Compress bool
+}
+
+// Writer appends to a file rotating it when it exceeds max size or max age
+type Writer struct {
+	mu       sync.Mutex
+	cfg      Config
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	retryAt  time.Time
+	now      func() time.Time
+
+	// compressing is done when the last rotated file is compressed
+	compressing sync.WaitGroup
+	// OnError is called with errors of background compression and of rotation
+	OnError func(err error)
+}
+
+// NewWriter opens or creates file to append to
+func NewWriter(cfg *Config) (*Writer, error) {
+	w := &Writer{
+		cfg: *cfg,
+		now: time.Now,
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Writer) open() error {
+	file, err := os.OpenFile(w.cfg.Filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("error open file %s: %s", w.cfg.Filename, err.Error())
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("error stat file %s: %s", w.cfg.Filename, err.Error())
+	}
+
+	w.file = file
+	w.size = info.Size()
+	w.openedAt = w.now()
+	return nil
+}
+
+// Write writes p to the file, the file is rotated before the write if it would exceed max size or is too old.
+// If rotation fails p is written to the current file and rotation is retried after rotateRetryInterval.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if w.size > 0 && w.needRotate(int64(len(p))) {
+		if err := w.rotate(); err != nil {
+			w.retryAt = w.now().Add(rotateRetryInterval)
+			w.onError(fmt.Errorf("error rotate file %s: %s", w.cfg.Filename, err.Error()))
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *Writer) needRotate(n int64) bool {
+	if w.now().Before(w.retryAt) {
+		return false
+	}
+	if w.cfg.MaxSize > 0 && w.size+n > w.cfg.MaxSize {
+		return true
+	}
+	return w.cfg.MaxAge > 0 && w.now().Sub(w.openedAt) >= w.cfg.MaxAge
+}
+
+// rotate moves the file to the backup and opens a new one.
+// The current file is moved aside before backups are touched and closed only after the new one is opened,
+// so backups are kept and the file stays usable if rotation fails.
+// If the file was moved by external tool it is just opened again.
+func (w *Writer) rotate() error {
+	// backups must not be renamed while the previous one is compressed
+	w.compressing.Wait()
+
+	if _, err := os.Stat(w.cfg.Filename); os.IsNotExist(err) {
+		return w.reopen()
+	}
+
+	rotating := w.cfg.Filename + rotatingSuffix
+	if err := os.Rename(w.cfg.Filename, rotating); err != nil {
+		return err
+	}
+
+	rotated, size, openedAt := w.file, w.size, w.openedAt
+	if err := w.open(); err != nil {
+		os.Rename(rotating, w.cfg.Filename)
+		return err
+	}
+
+	if w.cfg.MaxBackups == 0 {
+		rotated.Close()
+		return os.Remove(rotating)
+	}
+
+	w.removeBackup(w.cfg.MaxBackups)
+	for i := w.cfg.MaxBackups - 1; i > 0; i-- {
+		os.Rename(w.backupName(i), w.backupName(i+1))
+		os.Rename(w.backupName(i)+compressSuffix, w.backupName(i+1)+compressSuffix)
+	}
+
+	if err := os.Rename(rotating, w.backupName(1)); err != nil {
+		// the rotated file replaces the new empty one and stays current
+		w.file.Close()
+		os.Rename(rotating, w.cfg.Filename)
+		w.file, w.size, w.openedAt = rotated, size, openedAt
+		return err
+	}
+	rotated.Close()
+
+	if w.cfg.MaxBackups > 0 && w.cfg.Compress {
+		w.compressing.Add(1)
+		go func(name string) {
+			defer w.compressing.Done()
+			if err := compress(name); err != nil {
+				w.onError(err)
+			}
+		}(w.backupName(1))
+	}
+
+	return nil
+}
+
+// onError calls OnError in a new goroutine because the callback could write to this writer which lock is held
+func (w *Writer) onError(err error) {
+	if w.OnError != nil {
+		go w.OnError(err)
+	}
+}
+
+func (w *Writer) removeBackup(i int) {
+	os.Remove(w.backupName(i))
+	os.Remove(w.backupName(i) + compressSuffix)
+}
+
+func (w *Writer) backupName(i int) string {
+	return fmt.Sprintf("%s.%d", w.cfg.Filename, i)
+}
+
+// compress replaces file name with gzipped name.gz
+func compress(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return fmt.Errorf("error open rotated file: %s", err.Error())
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(name+compressSuffix, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("error create compressed file: %s", err.Error())
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		dst.Close()
+		os.Remove(name + compressSuffix)
+		return fmt.Errorf("error compress rotated file: %s", err.Error())
+	}
+
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		os.Remove(name + compressSuffix)
+		return fmt.Errorf("error compress rotated file: %s", err.Error())
+	}
+
+	if err := dst.Close(); err != nil {
+		return fmt.Errorf("error close compressed file: %s", err.Error())
+	}
+
+	return os.Remove(name)
+}
+
+// Reopen opens the file again, it is used after the file was moved by external tool.
+// The previous file is closed only after the new one is opened, so writes go on to it if opening fails.
+func (w *Writer) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return os.ErrClosed
+	}
+
+	return w.reopen()
+}
+
+func (w *Writer) reopen() error {
+	previous := w.file
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	return previous.Close()
+}
+
+// Close closes the file and waits until the rotated file is compressed
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.compressing.Wait()
+
+	if w.file == nil {
+		return nil
+	}
+
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
@@ -0,0 +1,184 @@
+package rotate
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type RotateSuite struct {
+	dir string
+}
+
+var _ = Suite(&RotateSuite{})
+
+// Hook up gocheck into the "go test" runner.
+func TestStart(t *testing.T) {
+	TestingT(t)
+}
+
+func (suite *RotateSuite) SetUpTest(c *C) {
+	var err error
+	suite.dir, err = ioutil.TempDir("", "rotate")
+	c.Assert(err, IsNil)
+}
+
+func (suite *RotateSuite) TearDownTest(c *C) {
+	os.RemoveAll(suite.dir)
+}
+
+func (suite *RotateSuite) read(c *C, name string) string {
+	data, err := ioutil.ReadFile(filepath.Join(suite.dir, name))
+	c.Assert(err, IsNil)
+	return string(data)
+}
+
+func (suite *RotateSuite) TestRotate(c *C) {
+	w, err := NewWriter(&Config{
+		Filename:   filepath.Join(suite.dir, "access.log"),
+		MaxSize:    8,
+		MaxBackups: 2,
+	})
+	c.Assert(err, IsNil)
+	defer w.Close()
+
+	for _, line := range []string{"aaaa\n", "bbb\n", "cccc\n", "dddd\n", "eeee\n"} {
+		_, err := w.Write([]byte(line))
+		c.Assert(err, IsNil)
+	}
+
+	c.Assert(suite.read(c, "access.log"), Equals, "eeee\n")
+	c.Assert(suite.read(c, "access.log.1"), Equals, "dddd\n")
+	c.Assert(suite.read(c, "access.log.2"), Equals, "cccc\n")
+
+	_, err = os.Stat(filepath.Join(suite.dir, "access.log.3"))
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (suite *RotateSuite) TestRotateFailureKeepsFile(c *C) {
+	// the backup could not be replaced by the file
+	c.Assert(os.MkdirAll(filepath.Join(suite.dir, "access.log.1", "dir"), 0755), IsNil)
+
+	errs := make(chan error, 10)
+	w, err := NewWriter(&Config{
+		Filename:   filepath.Join(suite.dir, "access.log"),
+		MaxSize:    8,
+		MaxBackups: 1,
+	})
+	c.Assert(err, IsNil)
+	w.OnError = func(err error) { errs <- err }
+	defer w.Close()
+
+	for _, line := range []string{"aaaa\n", "bbbb\n"} {
+		_, err := w.Write([]byte(line))
+		c.Assert(err, IsNil)
+	}
+
+	c.Assert(suite.read(c, "access.log"), Equals, "aaaa\nbbbb\n")
+	c.Assert(<-errs, ErrorMatches, "error rotate file .*")
+
+	// the backup is kept and rotation is not retried on every write
+	_, err = os.Stat(filepath.Join(suite.dir, "access.log.1", "dir"))
+	c.Assert(err, IsNil)
+	c.Assert(w.needRotate(100), Equals, false)
+}
+
+func (suite *RotateSuite) TestRotateMovedFileKeepsBackups(c *C) {
+	filename := filepath.Join(suite.dir, "access.log")
+	for i, backup := range []string{".1", ".2", ".3"} {
+		c.Assert(ioutil.WriteFile(filename+backup, []byte{byte('1' + i), '\n'}, 0644), IsNil)
+	}
+
+	w, err := NewWriter(&Config{Filename: filename, MaxSize: 8, MaxBackups: 3})
+	c.Assert(err, IsNil)
+	defer w.Close()
+
+	_, err = w.Write([]byte("aaaa\n"))
+	c.Assert(err, IsNil)
+
+	// the file is moved by external tool before the writer is reopened
+	c.Assert(os.Rename(filename, filename+".moved"), IsNil)
+
+	for _, line := range []string{"bbbb\n", "cc\n"} {
+		_, err := w.Write([]byte(line))
+		c.Assert(err, IsNil)
+	}
+
+	c.Assert(suite.read(c, "access.log"), Equals, "bbbb\ncc\n")
+	c.Assert(suite.read(c, "access.log.moved"), Equals, "aaaa\n")
+	c.Assert(suite.read(c, "access.log.1"), Equals, "1\n")
+	c.Assert(suite.read(c, "access.log.2"), Equals, "2\n")
+	c.Assert(suite.read(c, "access.log.3"), Equals, "3\n")
+}
+
+func (suite *RotateSuite) TestAppendAndReopen(c *C) {
+	filename := filepath.Join(suite.dir, "access.log")
+	c.Assert(ioutil.WriteFile(filename, []byte("old\n"), 0644), IsNil)
+
+	w, err := NewWriter(&Config{Filename: filename, MaxSize: 100})
+	c.Assert(err, IsNil)
+	defer w.Close()
+
+	_, err = w.Write([]byte("new\n"))
+	c.Assert(err, IsNil)
+	c.Assert(suite.read(c, "access.log"), Equals, "old\nnew\n")
+
+	c.Assert(os.Rename(filename, filename+".moved"), IsNil)
+	c.Assert(w.Reopen(), IsNil)
+
+	_, err = w.Write([]byte("next\n"))
+	c.Assert(err, IsNil)
+	c.Assert(suite.read(c, "access.log"), Equals, "next\n")
+
+	// the file could not be opened, writes go on to the previous one
+	c.Assert(os.Rename(filename, filename+".moved2"), IsNil)
+	c.Assert(os.Mkdir(filename, 0755), IsNil)
+	c.Assert(w.Reopen(), NotNil)
+
+	_, err = w.Write([]byte("last\n"))
+	c.Assert(err, IsNil)
+	c.Assert(suite.read(c, "access.log.moved2"), Equals, "next\nlast\n")
+}
+
+func (suite *RotateSuite) TestRotateByAgeWithCompression(c *C) {
+	filename := filepath.Join(suite.dir, "app.log")
+	now := time.Unix(1000, 0)
+
+	w := &Writer{
+		cfg: Config{Filename: filename, MaxAge: time.Hour, MaxBackups: 2, Compress: true},
+		now: func() time.Time { return now },
+	}
+	c.Assert(w.open(), IsNil)
+
+	for _, line := range []string{"first\n", "second\n", "third\n"} {
+		_, err := w.Write([]byte(line))
+		c.Assert(err, IsNil)
+		now = now.Add(time.Hour)
+	}
+	c.Assert(w.Close(), IsNil)
+
+	c.Assert(suite.read(c, "app.log"), Equals, "third\n")
+	c.Assert(suite.readGzip(c, "app.log.1.gz"), Equals, "second\n")
+	c.Assert(suite.readGzip(c, "app.log.2.gz"), Equals, "first\n")
+
+	_, err := os.Stat(filename + ".1")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (suite *RotateSuite) readGzip(c *C, name string) string {
+	file, err := os.Open(filepath.Join(suite.dir, name))
+	c.Assert(err, IsNil)
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	c.Assert(err, IsNil)
+
+	data, err := ioutil.ReadAll(gz)
+	c.Assert(err, IsNil)
+	return string(data)
+}
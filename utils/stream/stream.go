@@ -0,0 +1,77 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// IStream sends events to the client
+type IStream interface {
+	Send(event string, data interface{}) error
+}
+
+// SSE writes Server-Sent Events, every event is flushed to the client immediately.
+// Headers are written with the first event or heartbeat.
+type SSE struct {
+	mu      sync.Mutex
+	rw      http.ResponseWriter
+	flusher http.Flusher
+	started bool
+}
+
+func NewSSE(rw http.ResponseWriter, flusher http.Flusher) *SSE {
+	return &SSE{
+		rw:      rw,
+		flusher: flusher,
+	}
+}
+
+func (s *SSE) Send(event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.write(fmt.Sprintf("event: %s\ndata: %s\n\n", event, payload))
+}
+
+// Heartbeat sends a comment keeping the connection open through proxies
+func (s *SSE) Heartbeat() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.write(": heartbeat\n\n")
+}
+
+// Started returns whether anything is written to the client
+func (s *SSE) Started() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.started
+}
+
+func (s *SSE) write(message string) error {
+	if !s.started {
+		s.started = true
+
+		header := s.rw.Header()
+		header.Set("Content-Type", "text/event-stream")
+		header.Set("Cache-Control", "no-cache")
+		header.Set("Connection", "keep-alive")
+		header.Set("X-Accel-Buffering", "no")
+		s.rw.WriteHeader(http.StatusOK)
+	}
+
+	if _, err := s.rw.Write([]byte(message)); err != nil {
+		return err
+	}
+
+	s.flusher.Flush()
+	return nil
+}
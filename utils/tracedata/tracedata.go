@@ -1,9 +1,11 @@
 package tracedata
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
-	"math/rand"
 	"net/http"
+	"strings"
 
 	"golang.org/x/net/context"
 )
@@ -13,6 +15,39 @@ const (
 	TraceIDHeader      = "X-Trace-ID"
 	SpanIDHeader       = "X-Span-ID"
 	ParentSpanIDHeader = "X-Parent-Span-ID"
+
+	// W3C Trace Context headers (https://www.w3.org/TR/trace-context/)
+	TraceParentHeader = "traceparent"
+	TraceStateHeader  = "tracestate"
+
+	// B3 headers (https://github.com/openzipkin/b3-propagation)
+	B3Header             = "b3"
+	B3TraceIDHeader      = "X-B3-TraceId"
+	B3SpanIDHeader       = "X-B3-SpanId"
+	B3ParentSpanIDHeader = "X-B3-ParentSpanId"
+	B3SampledHeader      = "X-B3-Sampled"
+	B3FlagsHeader        = "X-B3-Flags"
+)
+
+// Formats of trace data propagation
+const (
+	FormatW3C     = "w3c"     // traceparent and tracestate headers
+	FormatB3      = "b3"      // single b3 header
+	FormatB3Multi = "b3multi" // X-B3-* headers
+	FormatCustom  = "custom"  // X-Trace-ID, X-Span-ID and X-Parent-Span-ID headers
+)
+
+// DefaultFormats are read in this order and all are written
+var DefaultFormats = []string{FormatW3C, FormatB3, FormatB3Multi, FormatCustom}
+
+// Sampling is a decision whether the trace is recorded
+type Sampling int
+
+const (
+	SamplingUndecided Sampling = iota
+	SamplingAccept
+	SamplingDeny
+	SamplingDebug
 )
 
 type dataCtxKeyType int
@@ -23,33 +58,102 @@ type TraceData struct {
 	TraceID      string
 	SpanID       string
 	ParentSpanID string
+	Sampling     Sampling
+	// TraceState is W3C vendor specific data passed unchanged
+	TraceState string
 }
 
-// TraceFromContext returns data from request
-func GetTraceDataFromRequest(req *http.Request) *TraceData {
-	traceID := req.Header.Get(TraceIDHeader)
-	spanID := req.Header.Get(SpanIDHeader)
-	parentSpanID := req.Header.Get(ParentSpanIDHeader)
+// Sampled returns true if the trace is recorded
+func (data *TraceData) Sampled() bool {
+	return data.Sampling == SamplingAccept || data.Sampling == SamplingDebug
+}
 
-	if traceID == "" {
-		traceID = generate()
-		spanID = traceID
-	} else if spanID == "" {
-		spanID = generate()
+type extractor func(header http.Header) *TraceData
+
+type injector func(data *TraceData, header http.Header)
+
+var extractors = map[string]extractor{
+	FormatW3C:     extractW3C,
+	FormatB3:      extractB3,
+	FormatB3Multi: extractB3Multi,
+	FormatCustom:  extractCustom,
+}
+
+var injectors = map[string]injector{
+	FormatW3C:     injectW3C,
+	FormatB3:      injectB3,
+	FormatB3Multi: injectB3Multi,
+	FormatCustom:  injectCustom,
+}
+
+// Propagator reads trace data of incoming requests in formats by precedence
+// and writes it to outgoing requests in all formats
+type Propagator struct {
+	formats []string
+}
+
+var defaultPropagator = &Propagator{formats: DefaultFormats}
+
+// NewPropagator creates propagator of formats, DefaultFormats are used if formats are empty
+func NewPropagator(formats []string) (*Propagator, error) {
+	if len(formats) == 0 {
+		formats = DefaultFormats
+	}
+
+	for _, format := range formats {
+		if _, ok := extractors[format]; !ok {
+			return nil, fmt.Errorf("unknown trace propagation format %q", format)
+		}
+	}
+
+	return &Propagator{formats: formats}, nil
+}
+
+// GetTraceDataFromRequest returns data of the first format found in request.
+// The caller span becomes the parent of a new span, a new trace is started if there is no trace data
+func (p *Propagator) GetTraceDataFromRequest(req *http.Request) *TraceData {
+	sampling := SamplingUndecided
+	for _, format := range p.formats {
+		data := extractors[format](req.Header)
+		if data == nil {
+			continue
+		}
+
+		if data.TraceID != "" {
+			if data.SpanID == "" {
+				data.SpanID = NewSpanID()
+			}
+			return data
+		}
+
+		// only sampling decision is propagated
+		if sampling == SamplingUndecided {
+			sampling = data.Sampling
+		}
 	}
 
 	return &TraceData{
-		TraceID:      traceID,
-		SpanID:       spanID,
-		ParentSpanID: parentSpanID,
+		TraceID:  NewTraceID(),
+		SpanID:   NewSpanID(),
+		Sampling: sampling,
 	}
 }
 
-// SetTraceDataToRequest sets data to request
+// SetTraceDataToRequest sets data to request in all formats of the propagator
+func (p *Propagator) SetTraceDataToRequest(data *TraceData, req *http.Request) {
+	for _, format := range p.formats {
+		injectors[format](data, req.Header)
+	}
+}
+
+// GetTraceDataFromRequest returns data from request in any of DefaultFormats
+func GetTraceDataFromRequest(req *http.Request) *TraceData {
+	return defaultPropagator.GetTraceDataFromRequest(req)
+}
+
+// SetTraceDataToRequest sets data to request in all of DefaultFormats
 func SetTraceDataToRequest(data *TraceData, req *http.Request) {
-	req.Header.Set(TraceIDHeader, data.TraceID)
-	req.Header.Set(SpanIDHeader, "") // if we do not send spanID then child service will generate spanID
-	req.Header.Set(ParentSpanIDHeader, data.SpanID)
+	defaultPropagator.SetTraceDataToRequest(data, req)
 }
 
 // SetTraceDataToContext creates new child context with given trace data
@@ -65,6 +169,286 @@ func GetTraceDataFromContext(ctx context.Context) *TraceData {
 	return &TraceData{}
 }
 
-func generate() string {
-	return fmt.Sprintf("%X", rand.Int63())
+// NewTraceID returns random 128-bit trace id as 32 lower hex characters
+func NewTraceID() string {
+	return generate(16)
+}
+
+// NewSpanID returns random 64-bit span id as 16 lower hex characters
+func NewSpanID() string {
+	return generate(8)
+}
+
+// generate returns random non-zero id, it panics if crypto/rand fails
+// as predictable ids could collide or be guessed
+func generate(size int) string {
+	id := make([]byte, size)
+	for {
+		if _, err := rand.Read(id); err != nil {
+			panic(fmt.Sprintf("error generate id: %s", err.Error()))
+		}
+
+		// zero ids are invalid
+		for _, b := range id {
+			if b != 0 {
+				return hex.EncodeToString(id)
+			}
+		}
+	}
+}
+
+// extractW3C reads traceparent formatted as version-traceid-parentid-flags,
+// the spec allows only lower case hex in it
+func extractW3C(header http.Header) *TraceData {
+	parts := strings.Split(strings.TrimSpace(header.Get(TraceParentHeader)), "-")
+	if len(parts) < 4 || !isLowerHex(parts[0], 2) || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return nil
+	}
+
+	traceID, spanID, flags := parts[1], parts[2], parts[3]
+	if !isLowerHex(traceID, 32) || !isID(traceID, 32) || !isLowerHex(spanID, 16) || !isID(spanID, 16) || !isLowerHex(flags, 2) {
+		return nil
+	}
+
+	sampling := SamplingDeny
+	if flag, _ := hex.DecodeString(flags); flag[0]&1 != 0 {
+		sampling = SamplingAccept
+	}
+
+	return &TraceData{
+		TraceID:      traceID,
+		ParentSpanID: spanID,
+		Sampling:     sampling,
+		TraceState:   strings.Join(header[http.CanonicalHeaderKey(TraceStateHeader)], ","),
+	}
+}
+
+func injectW3C(data *TraceData, header http.Header) {
+	traceID, ok := PadID(data.TraceID, 32)
+	if !ok {
+		return
+	}
+
+	spanID, ok := PadID(data.SpanID, 16)
+	if !ok {
+		return
+	}
+
+	flags := "00"
+	if data.Sampled() {
+		flags = "01"
+	}
+
+	header.Set(TraceParentHeader, "00-"+traceID+"-"+spanID+"-"+flags)
+	if data.TraceState != "" {
+		header.Set(TraceStateHeader, data.TraceState)
+	}
+}
+
+// extractB3 reads b3 header formatted as traceid-spanid-sampling-parentspanid
+// where the last two are optional, or only as sampling
+func extractB3(header http.Header) *TraceData {
+	value := strings.TrimSpace(header.Get(B3Header))
+	if value == "" {
+		return nil
+	}
+
+	parts := strings.Split(value, "-")
+	if len(parts) == 1 {
+		sampling, ok := parseB3Sampling(parts[0])
+		if !ok {
+			return nil
+		}
+		return &TraceData{Sampling: sampling}
+	}
+
+	if len(parts) > 4 || !isB3TraceID(parts[0]) || !isID(parts[1], 16) {
+		return nil
+	}
+
+	data := &TraceData{
+		TraceID:      strings.ToLower(parts[0]),
+		ParentSpanID: strings.ToLower(parts[1]),
+	}
+
+	if len(parts) > 2 {
+		var ok bool
+		if data.Sampling, ok = parseB3Sampling(parts[2]); !ok {
+			return nil
+		}
+	}
+
+	return data
+}
+
+func injectB3(data *TraceData, header http.Header) {
+	traceID, spanID, ok := b3IDs(data)
+	if !ok {
+		return
+	}
+
+	value := traceID + "-" + spanID
+	if sampling := formatB3Sampling(data.Sampling); sampling != "" {
+		value += "-" + sampling
+		if parentSpanID, ok := PadID(data.ParentSpanID, 16); ok {
+			value += "-" + parentSpanID
+		}
+	}
+
+	header.Set(B3Header, value)
+}
+
+func extractB3Multi(header http.Header) *TraceData {
+	sampling := SamplingUndecided
+	switch strings.ToLower(header.Get(B3SampledHeader)) {
+	case "1", "true":
+		sampling = SamplingAccept
+	case "0", "false":
+		sampling = SamplingDeny
+	}
+
+	if header.Get(B3FlagsHeader) == "1" {
+		sampling = SamplingDebug
+	}
+
+	traceID := header.Get(B3TraceIDHeader)
+	if traceID == "" {
+		if sampling == SamplingUndecided {
+			return nil
+		}
+		return &TraceData{Sampling: sampling}
+	}
+
+	spanID := header.Get(B3SpanIDHeader)
+	if !isB3TraceID(traceID) || (spanID != "" && !isID(spanID, 16)) {
+		return nil
+	}
+
+	return &TraceData{
+		TraceID:      strings.ToLower(traceID),
+		ParentSpanID: strings.ToLower(spanID),
+		Sampling:     sampling,
+	}
+}
+
+func injectB3Multi(data *TraceData, header http.Header) {
+	traceID, spanID, ok := b3IDs(data)
+	if !ok {
+		return
+	}
+
+	header.Set(B3TraceIDHeader, traceID)
+	header.Set(B3SpanIDHeader, spanID)
+	if parentSpanID, ok := PadID(data.ParentSpanID, 16); ok {
+		header.Set(B3ParentSpanIDHeader, parentSpanID)
+	}
+
+	switch data.Sampling {
+	case SamplingAccept:
+		header.Set(B3SampledHeader, "1")
+	case SamplingDeny:
+		header.Set(B3SampledHeader, "0")
+	case SamplingDebug:
+		header.Set(B3FlagsHeader, "1")
+	}
+}
+
+// extractCustom reads ids as they are, span id is assigned by the caller
+func extractCustom(header http.Header) *TraceData {
+	traceID := header.Get(TraceIDHeader)
+	if traceID == "" {
+		return nil
+	}
+
+	return &TraceData{
+		TraceID:      traceID,
+		SpanID:       header.Get(SpanIDHeader),
+		ParentSpanID: header.Get(ParentSpanIDHeader),
+	}
+}
+
+func injectCustom(data *TraceData, header http.Header) {
+	header.Set(TraceIDHeader, data.TraceID)
+	header.Set(SpanIDHeader, "") // if we do not send spanID then child service will generate spanID
+	header.Set(ParentSpanIDHeader, data.SpanID)
+}
+
+func parseB3Sampling(value string) (Sampling, bool) {
+	switch value {
+	case "1":
+		return SamplingAccept, true
+	case "0":
+		return SamplingDeny, true
+	case "d":
+		return SamplingDebug, true
+	}
+	return SamplingUndecided, false
+}
+
+func formatB3Sampling(sampling Sampling) string {
+	switch sampling {
+	case SamplingAccept:
+		return "1"
+	case SamplingDeny:
+		return "0"
+	case SamplingDebug:
+		return "d"
+	}
+	return ""
+}
+
+// b3IDs returns trace id of 16 or 32 and span id of 16 hex characters
+func b3IDs(data *TraceData) (string, string, bool) {
+	traceID, ok := PadTraceID(data.TraceID)
+	if !ok {
+		return "", "", false
+	}
+
+	spanID, ok := PadID(data.SpanID, 16)
+	return traceID, spanID, ok
+}
+
+// PadTraceID returns hex trace id left padded with zeros to 16 or 32 characters as B3 and Zipkin expect
+func PadTraceID(id string) (string, bool) {
+	if len(id) <= 16 {
+		return PadID(id, 16)
+	}
+	return PadID(id, 32)
+}
+
+func isB3TraceID(id string) bool {
+	return isID(id, 16) || isID(id, 32)
+}
+
+// PadID returns hex id of at most size characters in lower case left padded with zeros to size
+func PadID(id string, size int) (string, bool) {
+	if id == "" || len(id) > size || !isHex(id, len(id)) {
+		return "", false
+	}
+
+	id = strings.Repeat("0", size-len(id)) + strings.ToLower(id)
+	return id, isID(id, size)
+}
+
+// isID returns true if id is non-zero hex of size characters
+func isID(id string, size int) bool {
+	return isHex(id, size) && strings.Trim(id, "0") != ""
+}
+
+func isHex(s string, size int) bool {
+	if len(s) != size {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+func isLowerHex(s string, size int) bool {
+	return isHex(s, size) && strings.ToLower(s) == s
 }
@@ -0,0 +1,149 @@
+package tracedata
+
+import (
+	"net/http"
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+type TraceDataSuite struct{}
+
+var _ = Suite(&TraceDataSuite{})
+
+// Hook up gocheck into the "go test" runner.
+func TestStart(t *testing.T) {
+	TestingT(t)
+}
+
+func newRequest(headers map[string]string) *http.Request {
+	req, _ := http.NewRequest("GET", "/requestcount", nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	return req
+}
+
+func (suite *TraceDataSuite) TestNewTrace(c *C) {
+	data := GetTraceDataFromRequest(newRequest(nil))
+
+	c.Assert(isID(data.TraceID, 32), Equals, true)
+	c.Assert(isID(data.SpanID, 16), Equals, true)
+	c.Assert(data.ParentSpanID, Equals, "")
+	c.Assert(data.Sampling, Equals, SamplingUndecided)
+	c.Assert(GetTraceDataFromRequest(newRequest(nil)).TraceID, Not(Equals), data.TraceID)
+}
+
+func (suite *TraceDataSuite) TestW3C(c *C) {
+	data := GetTraceDataFromRequest(newRequest(map[string]string{
+		TraceParentHeader: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		TraceStateHeader:  "congo=t61rcWkgMzE",
+	}))
+
+	c.Assert(data.TraceID, Equals, "4bf92f3577b34da6a3ce929d0e0e4736")
+	c.Assert(data.ParentSpanID, Equals, "00f067aa0ba902b7")
+	c.Assert(isID(data.SpanID, 16), Equals, true)
+	c.Assert(data.Sampling, Equals, SamplingAccept)
+	c.Assert(data.TraceState, Equals, "congo=t61rcWkgMzE")
+
+	for _, invalid := range []string{
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"00-4bf92f3577b34da6-00f067aa0ba902b7-01",
+		// upper case hex is not allowed by the spec
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00F067AA0BA902B7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0A",
+	} {
+		data = GetTraceDataFromRequest(newRequest(map[string]string{TraceParentHeader: invalid}))
+		c.Assert(data.TraceID, Not(Equals), "4bf92f3577b34da6a3ce929d0e0e4736", Commentf(invalid))
+	}
+}
+
+func (suite *TraceDataSuite) TestB3(c *C) {
+	data := GetTraceDataFromRequest(newRequest(map[string]string{
+		B3Header: "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-d-05e3ac9a4f6e3b90",
+	}))
+
+	c.Assert(data.TraceID, Equals, "80f198ee56343ba864fe8b2a57d3eff7")
+	c.Assert(data.ParentSpanID, Equals, "e457b5a2e4d86bd1")
+	c.Assert(data.Sampling, Equals, SamplingDebug)
+
+	// upper case hex is accepted in b3
+	data = GetTraceDataFromRequest(newRequest(map[string]string{
+		B3Header: "80F198EE56343BA864FE8B2A57D3EFF7-E457B5A2E4D86BD1-1",
+	}))
+	c.Assert(data.TraceID, Equals, "80f198ee56343ba864fe8b2a57d3eff7")
+	c.Assert(data.ParentSpanID, Equals, "e457b5a2e4d86bd1")
+	c.Assert(data.Sampling, Equals, SamplingAccept)
+
+	data = GetTraceDataFromRequest(newRequest(map[string]string{B3Header: "0"}))
+	c.Assert(isID(data.TraceID, 32), Equals, true)
+	c.Assert(data.Sampling, Equals, SamplingDeny)
+}
+
+func (suite *TraceDataSuite) TestB3Multi(c *C) {
+	data := GetTraceDataFromRequest(newRequest(map[string]string{
+		B3TraceIDHeader:      "64fe8b2a57d3eff7",
+		B3SpanIDHeader:       "e457b5a2e4d86bd1",
+		B3ParentSpanIDHeader: "05e3ac9a4f6e3b90",
+		B3SampledHeader:      "0",
+	}))
+
+	c.Assert(data.TraceID, Equals, "64fe8b2a57d3eff7")
+	c.Assert(data.ParentSpanID, Equals, "e457b5a2e4d86bd1")
+	c.Assert(data.Sampling, Equals, SamplingDeny)
+}
+
+func (suite *TraceDataSuite) TestPrecedence(c *C) {
+	headers := map[string]string{
+		TraceParentHeader: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+		B3Header:          "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1",
+		TraceIDHeader:     "ABC",
+		SpanIDHeader:      "DEF",
+	}
+
+	c.Assert(GetTraceDataFromRequest(newRequest(headers)).TraceID, Equals, "4bf92f3577b34da6a3ce929d0e0e4736")
+
+	propagator, err := NewPropagator([]string{FormatCustom, FormatB3})
+	c.Assert(err, IsNil)
+	data := propagator.GetTraceDataFromRequest(newRequest(headers))
+	c.Assert(data.TraceID, Equals, "ABC")
+	c.Assert(data.SpanID, Equals, "DEF")
+
+	propagator, err = NewPropagator([]string{FormatB3Multi, FormatB3})
+	c.Assert(err, IsNil)
+	c.Assert(propagator.GetTraceDataFromRequest(newRequest(headers)).TraceID, Equals, "80f198ee56343ba864fe8b2a57d3eff7")
+
+	_, err = NewPropagator([]string{"jaeger"})
+	c.Assert(err, NotNil)
+}
+
+func (suite *TraceDataSuite) TestSetTraceDataToRequest(c *C) {
+	req := newRequest(nil)
+	SetTraceDataToRequest(&TraceData{
+		TraceID:      "64fe8b2a57d3eff7",
+		SpanID:       "e457b5a2e4d86bd1",
+		ParentSpanID: "05e3ac9a4f6e3b90",
+		Sampling:     SamplingAccept,
+		TraceState:   "congo=t61rcWkgMzE",
+	}, req)
+
+	c.Assert(req.Header.Get(TraceParentHeader), Equals, "00-000000000000000064fe8b2a57d3eff7-e457b5a2e4d86bd1-01")
+	c.Assert(req.Header.Get(TraceStateHeader), Equals, "congo=t61rcWkgMzE")
+	c.Assert(req.Header.Get(B3Header), Equals, "64fe8b2a57d3eff7-e457b5a2e4d86bd1-1-05e3ac9a4f6e3b90")
+	c.Assert(req.Header.Get(B3TraceIDHeader), Equals, "64fe8b2a57d3eff7")
+	c.Assert(req.Header.Get(B3SpanIDHeader), Equals, "e457b5a2e4d86bd1")
+	c.Assert(req.Header.Get(B3ParentSpanIDHeader), Equals, "05e3ac9a4f6e3b90")
+	c.Assert(req.Header.Get(B3SampledHeader), Equals, "1")
+	c.Assert(req.Header.Get(TraceIDHeader), Equals, "64fe8b2a57d3eff7")
+	c.Assert(req.Header.Get(ParentSpanIDHeader), Equals, "e457b5a2e4d86bd1")
+
+	// the child service reads the span as its parent
+	data := GetTraceDataFromRequest(req)
+	c.Assert(data.TraceID, Equals, "000000000000000064fe8b2a57d3eff7")
+	c.Assert(data.ParentSpanID, Equals, "e457b5a2e4d86bd1")
+	c.Assert(data.Sampling, Equals, SamplingAccept)
+}
@@ -0,0 +1,230 @@
+package tracing
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/THE108/requestcounter/utils/log"
+	"github.com/THE108/requestcounter/utils/metrics"
+	"github.com/THE108/requestcounter/utils/tracedata"
+
+	"golang.org/x/net/context"
+)
+
+// KindServer is a kind of spans of incoming requests
+const KindServer = "SERVER"
+
+type spanCtxKeyType int
+
+const spanCtxKey spanCtxKeyType = 0
+
+type Config struct {
+	ServiceName string
+	// CollectorURL is Zipkin v2 spans endpoint, e.g. http://localhost:9411/api/v2/spans
+	CollectorURL string
+	// SampleRate is a fraction of recorded traces which are not sampled by the caller
+	SampleRate    float64
+	QueueSize     int
+	BatchSize     int
+	FlushInterval time.Duration
+	MaxAttempts   int
+	// Backoff is a delay before the second attempt, it is doubled after every attempt up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	Timeout    time.Duration
+	Logger     log.ILogger
+}
+
+// Tracer records sampled spans and exports them in batches to Zipkin collector.
+// Methods of nil Tracer return nil spans so tracing could be disabled.
+type Tracer struct {
+	serviceName string
+	sampleRate  float64
+	exporter    *exporter
+}
+
+func NewTracer(cfg *Config) *Tracer {
+	return &Tracer{
+		serviceName: cfg.ServiceName,
+		sampleRate:  cfg.SampleRate,
+		exporter:    newExporter(cfg),
+	}
+}
+
+// Run starts the exporter
+func (t *Tracer) Run() error {
+	t.exporter.run()
+	return nil
+}
+
+// Close exports queued spans and stops the exporter
+func (t *Tracer) Close() error {
+	t.exporter.close()
+	return nil
+}
+
+// Dropped returns count of spans dropped because the queue was full or export failed
+func (t *Tracer) Dropped() uint64 {
+	t.exporter.mu.Lock()
+	defer t.exporter.mu.Unlock()
+	return t.exporter.dropped
+}
+
+// WriteMetrics writes counts of exported, dropped and queued spans
+func (t *Tracer) WriteMetrics(mw *metrics.Writer) {
+	e := t.exporter
+	e.mu.Lock()
+	dropped, sent := e.dropped, e.sent
+	e.mu.Unlock()
+
+	mw.Header("tracing_sent_spans_total", metrics.Counter, "Count of spans exported to the collector.")
+	mw.Sample("tracing_sent_spans_total", float64(sent))
+	mw.Header("tracing_dropped_spans_total", metrics.Counter, "Count of spans dropped because the queue was full or export failed.")
+	mw.Sample("tracing_dropped_spans_total", float64(dropped))
+	mw.Header("tracing_queued_spans", metrics.Gauge, "Count of spans waiting to be exported.")
+	mw.Sample("tracing_queued_spans", float64(len(e.queue)))
+}
+
+// StartServerSpan starts span of the request with ids of data.
+// Undecided sampling of data is decided by the sample rate so it is propagated further,
+// nil is returned if the trace is not sampled.
+func (t *Tracer) StartServerSpan(name string, data *tracedata.TraceData) *Span {
+	if t == nil {
+		return nil
+	}
+
+	if data.Sampling == tracedata.SamplingUndecided {
+		data.Sampling = tracedata.SamplingDeny
+		if t.sample() {
+			data.Sampling = tracedata.SamplingAccept
+		}
+	}
+
+	if !data.Sampled() {
+		return nil
+	}
+
+	// ids of the custom format are not necessarily hex
+	traceID, ok := tracedata.PadTraceID(data.TraceID)
+	if !ok {
+		return nil
+	}
+
+	spanID, ok := tracedata.PadID(data.SpanID, 16)
+	if !ok {
+		return nil
+	}
+
+	parentID, _ := tracedata.PadID(data.ParentSpanID, 16)
+
+	return t.newSpan(name, KindServer, traceID, spanID, parentID, data.Sampling == tracedata.SamplingDebug)
+}
+
+// StartSpan starts child span of parent or a new sampled trace if parent is nil.
+// nil is returned if the trace is not sampled.
+func (t *Tracer) StartSpan(name string, parent *Span) *Span {
+	if t == nil {
+		return nil
+	}
+
+	if parent == nil {
+		if !t.sample() {
+			return nil
+		}
+		return t.newSpan(name, "", tracedata.NewTraceID(), tracedata.NewSpanID(), "", false)
+	}
+
+	return t.newSpan(name, "", parent.TraceID, tracedata.NewSpanID(), parent.ID, parent.Debug)
+}
+
+func (t *Tracer) sample() bool {
+	return t.sampleRate >= 1 || rand.Float64() < t.sampleRate
+}
+
+func (t *Tracer) newSpan(name, kind, traceID, id, parentID string, debug bool) *Span {
+	return &Span{
+		TraceID:   traceID,
+		ID:        id,
+		ParentID:  parentID,
+		Name:      name,
+		Kind:      kind,
+		Debug:     debug,
+		Timestamp: time.Now(),
+		tracer:    t,
+	}
+}
+
+// Span is a timed operation of a trace
+type Span struct {
+	TraceID   string
+	ID        string
+	ParentID  string
+	Name      string
+	Kind      string
+	Debug     bool
+	Timestamp time.Time
+	Duration  time.Duration
+
+	mu       sync.Mutex
+	tags     map[string]string
+	finished bool
+	tracer   *Tracer
+}
+
+// SetTag sets tag of the span, the last value of key is kept
+func (s *Span) SetTag(key, value string) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	if s.tags == nil {
+		s.tags = make(map[string]string)
+	}
+	s.tags[key] = value
+	s.mu.Unlock()
+}
+
+// Tag returns value of the tag
+func (s *Span) Tag(key string) string {
+	if s == nil {
+		return ""
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tags[key]
+}
+
+// Finish sets duration of the span and queues it for export, calls after the first one are ignored
+func (s *Span) Finish() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	if s.finished {
+		s.mu.Unlock()
+		return
+	}
+	s.finished = true
+	s.Duration = time.Since(s.Timestamp)
+	span := s.zipkinSpan()
+	s.mu.Unlock()
+
+	s.tracer.exporter.add(span)
+}
+
+// SetSpanToContext creates new child context with given span
+func SetSpanToContext(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanCtxKey, span)
+}
+
+// GetSpanFromContext gets span from given context or nil
+func GetSpanFromContext(ctx context.Context) *Span {
+	if span, ok := ctx.Value(spanCtxKey).(*Span); ok {
+		return span
+	}
+	return nil
+}
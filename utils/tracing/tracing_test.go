@@ -0,0 +1,205 @@
+package tracing
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/THE108/requestcounter/utils/log"
+	"github.com/THE108/requestcounter/utils/tracedata"
+
+	. "gopkg.in/check.v1"
+)
+
+type TracingSuite struct{}
+
+var _ = Suite(&TracingSuite{})
+
+// Hook up gocheck into the "go test" runner.
+func TestStart(t *testing.T) {
+	TestingT(t)
+}
+
+// collector is a stand-in of Zipkin collector responding with statuses in order, then with 202
+type collector struct {
+	mu       sync.Mutex
+	statuses []int
+	requests int
+	spans    []map[string]interface{}
+}
+
+func (coll *collector) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	coll.mu.Lock()
+	defer coll.mu.Unlock()
+
+	coll.requests++
+	if len(coll.statuses) > 0 {
+		status := coll.statuses[0]
+		coll.statuses = coll.statuses[1:]
+		rw.WriteHeader(status)
+		return
+	}
+
+	body, _ := ioutil.ReadAll(req.Body)
+	var spans []map[string]interface{}
+	if err := json.Unmarshal(body, &spans); err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	coll.spans = append(coll.spans, spans...)
+	rw.WriteHeader(http.StatusAccepted)
+}
+
+// waitSpans waits until the collector received count spans
+func (coll *collector) waitSpans(count int) {
+	for i := 0; i < 100; i++ {
+		coll.mu.Lock()
+		received := len(coll.spans)
+		coll.mu.Unlock()
+
+		if received >= count {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func newTestTracer(url string, sampleRate float64, queueSize int) *Tracer {
+	return NewTracer(&Config{
+		ServiceName:   "requestcounter",
+		CollectorURL:  url,
+		SampleRate:    sampleRate,
+		QueueSize:     queueSize,
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+		MaxAttempts:   3,
+		Backoff:       time.Millisecond,
+		MaxBackoff:    time.Millisecond,
+		Timeout:       time.Second,
+		Logger:        log.New(ioutil.Discard, "", log.ERROR),
+	})
+}
+
+func (suite *TracingSuite) TestExport(c *C) {
+	coll := &collector{}
+	server := httptest.NewServer(coll)
+	defer server.Close()
+
+	tracer := newTestTracer(server.URL, 1, 10)
+	c.Assert(tracer.Run(), IsNil)
+
+	data := &tracedata.TraceData{
+		TraceID:      "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanID:       "e457b5a2e4d86bd1",
+		ParentSpanID: "00f067aa0ba902b7",
+	}
+	span := tracer.StartServerSpan("GetRequestCount", data)
+	c.Assert(span, NotNil)
+	c.Assert(data.Sampling, Equals, tracedata.SamplingAccept)
+
+	span.SetTag("http.status_code", "200")
+	child := tracer.StartSpan("flush counts", span)
+	child.Finish()
+	span.Finish()
+	span.Finish()
+
+	// the third span is exported on close
+	tracer.StartSpan("persist", nil).Finish()
+	c.Assert(tracer.Close(), IsNil)
+
+	coll.mu.Lock()
+	defer coll.mu.Unlock()
+
+	c.Assert(coll.requests, Equals, 2)
+	c.Assert(coll.spans, HasLen, 3)
+
+	c.Assert(coll.spans[0]["name"], Equals, "flush counts")
+	c.Assert(coll.spans[0]["traceId"], Equals, "4bf92f3577b34da6a3ce929d0e0e4736")
+	c.Assert(coll.spans[0]["parentId"], Equals, "e457b5a2e4d86bd1")
+
+	serverSpan := coll.spans[1]
+	c.Assert(serverSpan["name"], Equals, "GetRequestCount")
+	c.Assert(serverSpan["kind"], Equals, KindServer)
+	c.Assert(serverSpan["id"], Equals, "e457b5a2e4d86bd1")
+	c.Assert(serverSpan["parentId"], Equals, "00f067aa0ba902b7")
+	c.Assert(serverSpan["tags"], DeepEquals, map[string]interface{}{"http.status_code": "200"})
+	c.Assert(serverSpan["localEndpoint"], DeepEquals, map[string]interface{}{"serviceName": "requestcounter"})
+	c.Assert(serverSpan["timestamp"].(float64) > 0, Equals, true)
+	c.Assert(serverSpan["duration"].(float64) >= 1, Equals, true)
+
+	c.Assert(coll.spans[2]["name"], Equals, "persist")
+	c.Assert(coll.spans[2]["parentId"], IsNil)
+	c.Assert(tracer.Dropped(), Equals, uint64(0))
+}
+
+func (suite *TracingSuite) TestRetry(c *C) {
+	coll := &collector{statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}}
+	server := httptest.NewServer(coll)
+	defer server.Close()
+
+	tracer := newTestTracer(server.URL, 1, 10)
+	tracer.Run()
+	tracer.StartSpan("persist", nil).Finish()
+	tracer.StartSpan("persist", nil).Finish()
+
+	// retries are stopped on close
+	coll.waitSpans(2)
+	tracer.Close()
+
+	coll.mu.Lock()
+	c.Assert(coll.requests, Equals, 3)
+	c.Assert(coll.spans, HasLen, 2)
+	coll.mu.Unlock()
+
+	// client errors are not retried
+	coll = &collector{statuses: []int{http.StatusBadRequest}}
+	server2 := httptest.NewServer(coll)
+	defer server2.Close()
+
+	tracer = newTestTracer(server2.URL, 1, 10)
+	tracer.Run()
+	tracer.StartSpan("persist", nil).Finish()
+	tracer.Close()
+
+	c.Assert(coll.requests, Equals, 1)
+	c.Assert(tracer.Dropped(), Equals, uint64(1))
+}
+
+func (suite *TracingSuite) TestSampling(c *C) {
+	tracer := newTestTracer("", 0.000001, 10)
+
+	data := &tracedata.TraceData{TraceID: "64fe8b2a57d3eff7", SpanID: "e457b5a2e4d86bd1"}
+	c.Assert(tracer.StartServerSpan("GetRequestCount", data), IsNil)
+	c.Assert(data.Sampling, Equals, tracedata.SamplingDeny)
+
+	// decision of the caller is kept
+	data = &tracedata.TraceData{TraceID: "64fe8b2a57d3eff7", SpanID: "e457b5a2e4d86bd1", Sampling: tracedata.SamplingDebug}
+	span := tracer.StartServerSpan("GetRequestCount", data)
+	c.Assert(span, NotNil)
+	c.Assert(span.Debug, Equals, true)
+	c.Assert(tracer.StartSpan("flush counts", span).Debug, Equals, true)
+
+	// ids which are not hex are not recorded
+	data = &tracedata.TraceData{TraceID: "ABC-1", SpanID: "DEF", Sampling: tracedata.SamplingAccept}
+	c.Assert(tracer.StartServerSpan("GetRequestCount", data), IsNil)
+
+	// nil tracer and spans are no-op
+	var disabled *Tracer
+	span = disabled.StartSpan("persist", nil)
+	c.Assert(span, IsNil)
+	span.SetTag("error", "failed")
+	span.Finish()
+}
+
+func (suite *TracingSuite) TestQueueFull(c *C) {
+	tracer := newTestTracer("", 1, 1)
+	tracer.StartSpan("persist", nil).Finish()
+	tracer.StartSpan("persist", nil).Finish()
+
+	c.Assert(tracer.Dropped(), Equals, uint64(1))
+}
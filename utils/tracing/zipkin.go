@@ -0,0 +1,223 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/THE108/requestcounter/utils/log"
+)
+
+// zipkinSpan is a span in Zipkin v2 JSON format (https://zipkin.io/zipkin-api/#/default/post_spans)
+type zipkinSpan struct {
+	TraceID       string            `json:"traceId"`
+	ID            string            `json:"id"`
+	ParentID      string            `json:"parentId,omitempty"`
+	Name          string            `json:"name"`
+	Kind          string            `json:"kind,omitempty"`
+	Timestamp     int64             `json:"timestamp"`
+	Duration      int64             `json:"duration"`
+	Debug         bool              `json:"debug,omitempty"`
+	LocalEndpoint *zipkinEndpoint   `json:"localEndpoint,omitempty"`
+	Tags          map[string]string `json:"tags,omitempty"`
+}
+
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName"`
+}
+
+// zipkinSpan converts the finished span, timings are in microseconds
+func (s *Span) zipkinSpan() *zipkinSpan {
+	duration := int64(s.Duration / time.Microsecond)
+	if duration < 1 {
+		duration = 1
+	}
+
+	tags := make(map[string]string, len(s.tags))
+	for key, value := range s.tags {
+		tags[key] = value
+	}
+
+	return &zipkinSpan{
+		TraceID:       s.TraceID,
+		ID:            s.ID,
+		ParentID:      s.ParentID,
+		Name:          s.Name,
+		Kind:          s.Kind,
+		Timestamp:     s.Timestamp.UnixNano() / int64(time.Microsecond),
+		Duration:      duration,
+		Debug:         s.Debug,
+		LocalEndpoint: &zipkinEndpoint{ServiceName: s.tracer.serviceName},
+		Tags:          tags,
+	}
+}
+
+// exporter posts batches of spans to the collector retrying failed requests with exponential backoff.
+// Spans are dropped if the queue is full or all attempts failed.
+type exporter struct {
+	url           string
+	queue         chan *zipkinSpan
+	batchSize     int
+	flushInterval time.Duration
+	client        *http.Client
+	maxAttempts   int
+	backoff       time.Duration
+	maxBackoff    time.Duration
+	done          chan struct{}
+	wg            sync.WaitGroup
+	logger        log.ILogger
+
+	mu      sync.Mutex
+	dropped uint64
+	sent    uint64
+}
+
+func newExporter(cfg *Config) *exporter {
+	return &exporter{
+		url:           cfg.CollectorURL,
+		queue:         make(chan *zipkinSpan, cfg.QueueSize),
+		batchSize:     cfg.BatchSize,
+		flushInterval: cfg.FlushInterval,
+		client:        &http.Client{Timeout: cfg.Timeout},
+		maxAttempts:   cfg.MaxAttempts,
+		backoff:       cfg.Backoff,
+		maxBackoff:    cfg.MaxBackoff,
+		done:          make(chan struct{}),
+		logger:        cfg.Logger,
+	}
+}
+
+func (e *exporter) run() {
+	e.wg.Add(1)
+	go e.runExport()
+}
+
+func (e *exporter) close() {
+	close(e.done)
+	e.wg.Wait()
+}
+
+func (e *exporter) add(span *zipkinSpan) {
+	select {
+	case e.queue <- span:
+	default:
+		e.drop(1)
+	}
+}
+
+func (e *exporter) drop(count int) {
+	e.mu.Lock()
+	e.dropped += uint64(count)
+	e.mu.Unlock()
+}
+
+func (e *exporter) runExport() {
+	defer e.wg.Done()
+
+	batch := make([]*zipkinSpan, 0, e.batchSize)
+	ticker := time.NewTicker(e.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case span := <-e.queue:
+			batch = append(batch, span)
+			if len(batch) >= e.batchSize {
+				batch = e.export(batch)
+			}
+		case <-ticker.C:
+			batch = e.export(batch)
+		case <-e.done:
+			// spans queued before closing are exported with a single attempt
+			for {
+				select {
+				case span := <-e.queue:
+					batch = append(batch, span)
+					if len(batch) >= e.batchSize {
+						batch = e.export(batch)
+					}
+				default:
+					e.export(batch)
+					e.logger.Debug("span exporter is done")
+					return
+				}
+			}
+		}
+	}
+}
+
+// export sends batch and returns it emptied for reuse
+func (e *exporter) export(batch []*zipkinSpan) []*zipkinSpan {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	if err := e.send(batch); err != nil {
+		e.drop(len(batch))
+		e.logger.Errorf("error export %d spans: %s", len(batch), err.Error())
+	} else {
+		e.mu.Lock()
+		e.sent += uint64(len(batch))
+		e.mu.Unlock()
+	}
+
+	for i := range batch {
+		batch[i] = nil
+	}
+	return batch[:0]
+}
+
+func (e *exporter) send(batch []*zipkinSpan) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+
+	backoff := e.backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := e.post(body)
+		if err == nil {
+			return nil
+		}
+
+		if !retry || attempt >= e.maxAttempts {
+			return fmt.Errorf("%s (attempts: %d)", err.Error(), attempt)
+		}
+
+		e.logger.Warningf("error export spans, retry in %s: %s", backoff, err.Error())
+
+		select {
+		case <-time.After(backoff):
+		case <-e.done:
+			return fmt.Errorf("%s (attempts: %d, exporter is closed)", err.Error(), attempt)
+		}
+
+		backoff *= 2
+		if backoff > e.maxBackoff {
+			backoff = e.maxBackoff
+		}
+	}
+}
+
+// post sends body to the collector, returns whether failed request should be retried
+func (e *exporter) post(body []byte) (bool, error) {
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("collector responded with status %d", resp.StatusCode)
+}